@@ -2,7 +2,6 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 )
 
 // Action is to implement Capability interface
@@ -16,7 +15,7 @@ type Capability interface {
 	SetPrepares([]*Connection)
 	SetNextpages([]*Connection)
 	SetAppendix(interface{})
-	RunActionContext(context.Context, *sql.DB, *Table, map[string]interface{}, ...map[string]interface{}) ([]map[string]interface{}, error)
+	RunActionContext(context.Context, Executor, *Table, map[string]interface{}, ...map[string]interface{}) ([]map[string]interface{}, error)
 }
 
 type Action struct {
@@ -25,6 +24,11 @@ type Action struct {
 	Nextpages []*Connection `json:"nextpages,omitempty" hcl:"nextpages,block"`
 	IsDo      bool          `json:"isDo,omitempty" hcl:"isDo,optional"`
 	Appendix  interface{}   `json:"appendix,omitempty" hcl:"appendix,block"`
+	// Before and After: the names of registered hooks around the action
+	Before    []string      `json:"before,omitempty" hcl:"before,optional"`
+	After     []string      `json:"after,omitempty" hcl:"after,optional"`
+	befores   []BeforeHook
+	afters    []AfterHook
 }
 
 func (self *Action) GetActionName() string {
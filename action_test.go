@@ -1,6 +1,7 @@
 package godbi
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 )
@@ -8,13 +9,13 @@ import (
 func TestAction(t *testing.T) {
 	db, err := getdb()
 	if err != nil {
-		panic(err)
+		skipNoDB(t, err)
 	}
 	defer db.Close()
 
 	db.Exec(`drop table if exists m_a`)
-	db.Exec(`CREATE TABLE m_a (id int auto_increment not null primary key,
-        x varchar(8), y varchar(8), z varchar(8))`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8), UNIQUE (x, y))`))
 
 	tstr := `{
     "tableName":"m_a",
@@ -33,6 +34,7 @@ func TestAction(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	table.SetQuestionNumber(testDBType)
 
 	insert := new(Insert)
 	insert.IsDo = true
@@ -124,3 +126,54 @@ func TestAction(t *testing.T) {
 	db.Exec(`drop table if exists m_a`)
 	db.Exec(`drop table if exists m_b`)
 }
+
+func TestActionTx(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8))`))
+
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Columns: []*Col{
+		{ColumnName: "x", TypeName: "string", Label: "x", Notnull: true},
+		{ColumnName: "y", TypeName: "string", Label: "y", Notnull: true},
+		{ColumnName: "z", TypeName: "string", Label: "z"},
+		{ColumnName: "id", TypeName: "int", Label: "id", Auto: true}}}
+	table.SetQuestionNumber(testDBType)
+	insert := &Insert{Action: Action{IsDo: true}}
+	topics := new(Topics)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]interface{}{"x": "a1234567", "y": "b1234567", "z": "temp"}
+	if _, err = insert.RunActionContext(ctx, tx, table, args); err != nil {
+		t.Fatal(err)
+	}
+	lists, err := topics.RunActionContext(ctx, tx, table, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 {
+		t.Errorf("%v", lists)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	lists, err = topics.RunAction(db, table, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 0 {
+		t.Errorf("%v", lists)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+}
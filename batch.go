@@ -0,0 +1,279 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// batchKey is a relation of a nextpage, from the column of the current
+// page to the column, and its output label, of the nextpage.
+//
+type batchKey struct {
+	parent string
+	column string
+	label  string
+}
+
+// batchKeys returns the relations of nextpage p, if the nextpage can run
+// once for all rows of data, instead of once per row. That is, p is a
+// read action related by RelateExtra only, with no paging nor constraint
+// overriding the relations, and whose output has the related columns.
+//
+func (self *Graph) batchKeys(p *Connection, pAction Capability, v interface{}, newExtra map[string]interface{}, data []map[string]interface{}) []batchKey {
+	if len(data) < 2 || pAction.GetIsDo() || hasValue(p.RelateArgs) || !hasValue(p.RelateExtra) {
+		return nil
+	}
+	if _, ok := p.RelateExtra["ALL"]; ok {
+		return nil
+	}
+
+	fieldsName := ""
+	switch t := pAction.(type) {
+	case *Topics:
+		if t.Keyset {
+			return nil
+		}
+		t.setDefaultElementNames()
+		for _, args := range []interface{}{v, self.graphArgs(p.TableName, p.ActionName)} {
+			if hash, ok := args.(map[string]interface{}); ok {
+				if _, ok := hash[t.ROWCOUNT]; ok {
+					return nil
+				}
+			}
+		}
+		fieldsName = t.FIELDS
+	case *Edit:
+		t.setDefaultElementNames()
+		fieldsName = t.FIELDS
+	default:
+	}
+	// the selected fields, which the action reads from its args
+	var fields []string
+	for _, args := range []interface{}{v, self.graphArgs(p.TableName, p.ActionName)} {
+		if hash, ok := args.(map[string]interface{}); ok && fieldsName != "" {
+			if s, ok := hash[fieldsName].([]string); ok {
+				fields = s
+			}
+		}
+	}
+
+	var parents []string
+	for k := range p.RelateExtra {
+		parents = append(parents, k)
+	}
+	sort.Strings(parents)
+
+	table := self.GetModel(p.TableName).GetTable()
+	found := p.FindExtra(newExtra)
+	graphExtra := self.graphExtra(p.TableName, p.ActionName)
+	var keys []batchKey
+	for _, parent := range parents {
+		column := p.RelateExtra[parent]
+		if _, ok := found[column]; ok {
+			return nil
+		}
+		if _, ok := graphExtra[column]; ok {
+			return nil
+		}
+		name := column
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			name = column[i+1:]
+		}
+		// the output must have the column to match the rows
+		if fields != nil && !grep(fields, name) {
+			return nil
+		}
+		label := ""
+		for _, col := range table.Columns {
+			if col.ColumnName == name {
+				label = col.Label
+				break
+			}
+		}
+		if label == "" {
+			return nil
+		}
+		keys = append(keys, batchKey{parent, column, label})
+	}
+	return keys
+}
+
+// graphArgs returns the args of model and action set in Initialize.
+//
+func (self *Graph) graphArgs(model, action string) interface{} {
+	if argsAction, ok := self.argsMap[model].(map[string]interface{}); ok {
+		return argsAction[action]
+	}
+	return nil
+}
+
+// graphExtra returns the extra of model and action set in Initialize.
+//
+func (self *Graph) graphExtra(model, action string) map[string]interface{} {
+	if extraAction, ok := self.extraMap[model].(map[string]interface{}); ok {
+		if extra, ok := extraAction[action].(map[string]interface{}); ok {
+			return extra
+		}
+	}
+	return nil
+}
+
+// batchValue returns the value of key as a string, to match rows of the
+// current page and of the nextpage whose values may differ in Go type.
+// It returns false if the value can not be batched.
+//
+func batchValue(item map[string]interface{}, key string) (string, bool) {
+	v, ok := item[key]
+	if !ok || v == nil {
+		return "", false
+	}
+	switch t := v.(type) {
+	case []byte:
+		return string(t), true
+	case map[string]interface{}:
+		return "", false
+	default:
+		if _, ok := toSlice(t); ok {
+			return "", false
+		}
+	}
+	return fmt.Sprint(v), true
+}
+
+// batchSize returns the number of rows of a batched nextpage in a chunk,
+// so that the IN constraints of keys relations take at most half of the
+// placeholders, leaving the rest to the other constraints, and no IN has
+// more than 1000 values in Oracle (ORA-01795).
+//
+func batchSize(limit, keys int, dbType DBType) int {
+	size := limit / (2 * keys)
+	if dbType == Oracle && size > 1000 {
+		size = 1000
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// batchNextpage runs nextpage p once, with IN constraints collected from
+// all rows of data, and distributes the output rows to each row, as if p
+// was run on the rows one by one. The rows are run in chunks under the
+// placeholder limit. It returns the shortened output of each row, or false
+// if p can not be batched.
+//
+func (self *Graph) batchNextpage(ctx context.Context, db Executor, p *Connection, pAction Capability, v interface{}, newExtra map[string]interface{}, data []map[string]interface{}) ([]interface{}, bool, error) {
+	keys := self.batchKeys(p, pAction, v, newExtra, data)
+	if keys == nil {
+		return nil, false, nil
+	}
+
+	// the tuple of the relations of each row, and the distinct tuples
+	type tuple struct {
+		parts  []string
+		values []interface{}
+	}
+	tuples := make([]string, len(data))
+	skips := make([]bool, len(data))
+	var distinct []tuple
+	seen := make(map[string]bool)
+	for j, item := range data {
+		var current tuple
+		for _, key := range keys {
+			s, ok := batchValue(item, key.parent)
+			if !ok {
+				// the row finds nothing when run alone, or can not be batched
+				if _, exists := item[key.parent]; exists && item[key.parent] == nil {
+					skips[j] = true
+					break
+				}
+				return nil, false, nil
+			}
+			current.parts = append(current.parts, s)
+			current.values = append(current.values, item[key.parent])
+		}
+		if skips[j] {
+			continue
+		}
+		tuples[j] = strings.Join(current.parts, "\x00")
+		if !seen[tuples[j]] {
+			seen[tuples[j]] = true
+			distinct = append(distinct, current)
+		}
+	}
+	if distinct == nil {
+		return make([]interface{}, len(data)), true, nil
+	}
+
+	dbType := self.GetModel(p.TableName).GetTable().questionNumber
+	limit, err := placeholderLimit(ctx, db, dbType)
+	if err != nil { return nil, true, err }
+	size := batchSize(limit, len(keys), dbType)
+
+	var newLists []map[string]interface{}
+	for start := 0; start < len(distinct); start += size {
+		end := start + size
+		if end > len(distinct) {
+			end = len(distinct)
+		}
+		// the IN values of each column in the chunk
+		nextExtra := make(map[string]interface{})
+		for i, key := range keys {
+			var values []interface{}
+			used := make(map[string]bool)
+			for _, current := range distinct[start:end] {
+				if !used[current.parts[i]] {
+					used[current.parts[i]] = true
+					values = append(values, current.values[i])
+				}
+			}
+			if len(values) == 1 {
+				nextExtra[key.column] = values[0]
+			} else {
+				nextExtra[key.column] = values
+			}
+		}
+		nextExtra = MergeExtra(nextExtra, p.FindExtra(newExtra))
+
+		lists, err := self.runConnection(ctx, db, p, false, v, nextExtra)
+		if err != nil { return nil, true, err }
+		newLists = append(newLists, lists...)
+	}
+
+	groups := make(map[string][]map[string]interface{})
+	for _, row := range newLists {
+		var parts []string
+		for _, key := range keys {
+			s, ok := batchValue(row, key.label)
+			if !ok {
+				// the relation is not in the output, so run the rows one by one
+				return nil, false, nil
+			}
+			parts = append(parts, s)
+		}
+		tuple := strings.Join(parts, "\x00")
+		groups[tuple] = append(groups[tuple], row)
+	}
+
+	outputs := make([]interface{}, len(data))
+	used := make(map[string]bool)
+	for j := range data {
+		lists := groups[tuples[j]]
+		if skips[j] || !hasValue(lists) {
+			continue
+		}
+		// rows sharing the relations get their own copies, as if run alone
+		if used[tuples[j]] {
+			copies := make([]map[string]interface{}, len(lists))
+			for i, row := range lists {
+				copies[i] = CloneExtra(row)
+			}
+			lists = copies
+		}
+		used[tuples[j]] = true
+		outputs[j] = p.Shorten(lists)
+	}
+	return outputs, true, nil
+}
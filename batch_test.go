@@ -0,0 +1,149 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// countExecutor counts the queries run on Executor.
+//
+type countExecutor struct {
+	Executor
+	n int
+}
+
+func (self *countExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	self.n++
+	return self.Executor.QueryContext(ctx, query, args...)
+}
+
+func TestBatch(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+
+	for _, x := range []string{"a", "b", "c", "d"} {
+		if _, err = db.Exec(`INSERT INTO m_a (x, y) VALUES (?, 'y')`, x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, child := range [][2]interface{}{{1, "c1"}, {1, "c2"}, {2, "c3"}, {3, "c4"}, {1, "c5"}} {
+		if _, err = db.Exec(`INSERT INTO m_b (id, child) VALUES (?, ?)`, child[0], child[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// m_a topics, with m_a edit and m_b topics nested in the nextpages
+	counter := &countExecutor{Executor: db}
+	lists, err := graph.RunContext(ctx, counter, "m_a", "topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.n != 3 {
+		t.Errorf("%d queries", counter.n)
+	}
+	var streamed []map[string]interface{}
+	err = graph.StreamContext(ctx, db, func(item map[string]interface{}) error {
+		streamed = append(streamed, item)
+		return nil
+	}, "m_a", "topics")
+	if err != nil || !reflect.DeepEqual(lists, streamed) {
+		t.Errorf("%v\n%#v\n%#v", err, lists, streamed)
+	}
+	edit := lists[0]["m_a_edit"].([]map[string]interface{})
+	children := edit[0]["m_b_topics"].([]map[string]interface{})
+	if len(children) != 3 || children[0]["child"] != "c1" || children[2]["child"] != "c5" {
+		t.Errorf("%#v", children)
+	}
+	edit = lists[3]["m_a_edit"].([]map[string]interface{})
+	if _, ok := edit[0]["m_b_topics"]; ok {
+		t.Errorf("%#v", edit)
+	}
+
+	// the shape of each dimension is kept
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"], "idAuto":"id",
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int", "auto":true}],
+"actions":[{"actionName":"topics", "nextpages":[
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}, "marker":"child", "dimension":2},
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}, "marker":"first", "dimension":1}]}]},
+{"tableName":"m_b", "pks":["tid"], "idAuto":"tid",
+"columns":[{"columnName":"tid", "label":"tid", "typeName":"int", "auto":true}, {"columnName":"child", "label":"child", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetQuestionNumber(testDBType)
+	counter.n = 0
+	lists, err = graph.RunContext(ctx, counter, "m_a", "topics")
+	if err != nil || counter.n != 3 {
+		t.Fatalf("%d %v", counter.n, err)
+	}
+	if !reflect.DeepEqual(lists[0]["child"], []interface{}{"c1", "c2", "c5"}) || lists[1]["first"].(map[string]interface{})["child"] != "c3" || lists[3]["child"] != nil {
+		t.Errorf("%#v", lists)
+	}
+
+	// a nextpage whose fields miss the relation runs on each row, without
+	// the batched query first
+	graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"topics": map[string]interface{}{"fields": []string{"child"}}}}, nil)
+	counter.n = 0
+	lists, err = graph.RunContext(ctx, counter, "m_a", "topics")
+	if err != nil || counter.n != 9 || !reflect.DeepEqual(lists[0]["child"], []interface{}{"c1", "c2", "c5"}) {
+		t.Errorf("%d %v %#v", counter.n, err, lists)
+	}
+	graph.Initialize(nil, nil)
+
+	// the rows are batched in chunks under the placeholder limit, which is
+	// 999 before SQLite 3.32
+	if size := batchSize(65535, 1, Oracle); size != 1000 {
+		t.Errorf("%d", size)
+	}
+	if size := batchSize(2100, 2, SQLServer); size != 525 {
+		t.Errorf("%d", size)
+	}
+	if testDBType == SQLite {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1200; i++ {
+			if _, err = tx.Exec(`INSERT INTO m_a (x, y) VALUES ('e', ?)`, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		sqliteVersion.Lock()
+		major, minor := sqliteVersion.major, sqliteVersion.minor
+		sqliteVersion.major, sqliteVersion.minor = 3, 31
+		sqliteVersion.Unlock()
+		counter.n = 0
+		lists, err = graph.RunContext(ctx, counter, "m_a", "topics")
+		sqliteVersion.Lock()
+		sqliteVersion.major, sqliteVersion.minor = major, minor
+		sqliteVersion.Unlock()
+		// 1204 rows in 3 chunks of 499, for each of the 2 nextpages
+		if err != nil || len(lists) != 1204 || counter.n != 7 || !reflect.DeepEqual(lists[0]["child"], []interface{}{"c1", "c2", "c5"}) {
+			t.Errorf("%d %v %d", counter.n, err, len(lists))
+		}
+		db.Exec(`DELETE FROM m_a WHERE id > 4`)
+	}
+
+	// a paged nextpage runs on each row
+	graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"topics": map[string]interface{}{"rowcount": 1}}}, nil)
+	counter.n = 0
+	lists, err = graph.RunContext(ctx, counter, "m_a", "topics")
+	if err != nil || counter.n != 9 || !reflect.DeepEqual(lists[0]["child"], []interface{}{"c1"}) {
+		t.Errorf("%d %v %#v", counter.n, err, lists)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
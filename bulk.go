@@ -0,0 +1,332 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BulkInsert inserts all rows of the input, in multi-row INSERT statements.
+//
+type BulkInsert struct {
+	Action
+}
+
+// bulkRunner is an action which runs all rows of a slice input at once,
+// instead of once per row.
+//
+type bulkRunner interface {
+	RunBulkContext(context.Context, Executor, *Table, []map[string]interface{}, ...map[string]interface{}) ([]map[string]interface{}, error)
+}
+
+// placeholderLimit is the maximal number of placeholders in a statement,
+// which is 999 in SQLite before 3.32.
+//
+func placeholderLimit(ctx context.Context, db Executor, dbType DBType) (int, error) {
+	switch dbType {
+	case SQLite:
+		since, err := sqliteSince(ctx, db, 32)
+		if err != nil || !since {
+			return 999, err
+		}
+		return 32766, nil
+	case SQLServer:
+		return 2100, nil
+	case Oracle:
+		return 65535, nil
+	default:
+	}
+	return 65535, nil
+}
+
+// bulkStatement returns the statement to insert n rows of columns into
+// table, which also returns the ids of idAuto, if the dialect allows.
+//
+func bulkStatement(table string, columns []string, n int, idAuto string, dbType DBType) string {
+	row := "(" + strings.Join(strings.Split(strings.Repeat("?", len(columns)), ""), ",") + ")"
+	names := " (" + strings.Join(quoteNames(columns, dbType), ", ") + ")"
+	table = quoteName(table, dbType)
+	idAuto = quoteName(idAuto, dbType)
+
+	if dbType == Oracle {
+		sql := "INSERT ALL"
+		for i := 0; i < n; i++ {
+			sql += "\nINTO " + table + names + " VALUES " + row
+		}
+		return questionMarker(sql+"\nSELECT 1 FROM DUAL", dbType)
+	}
+
+	rows := strings.Repeat(","+row, n)[1:]
+	sql := "INSERT INTO " + table + names + " VALUES " + rows
+	if idAuto != "" {
+		switch dbType {
+		case Postgres, SQLite:
+			sql += " RETURNING " + idAuto
+		case SQLServer:
+			sql = "INSERT INTO " + table + names + " OUTPUT INSERTED." + idAuto + " VALUES " + rows
+		default:
+		}
+	}
+	return questionMarker(sql, dbType)
+}
+
+// BulkInsert inserts rows of columns into table, in chunks of multi-row VALUES under
+// the placeholder limit of DBType, and returns the ids of idAuto if the dialect allows.
+//
+func (self *DBI) BulkInsert(table string, columns []string, rows [][]interface{}, idAuto string) ([]int64, error) {
+	return self.BulkInsertContext(context.Background(), table, columns, rows, idAuto)
+}
+
+// BulkInsertContext inserts rows of columns into table, in chunks of multi-row VALUES under
+// the placeholder limit of DBType, in one transaction if DB can begin it.
+// The ids of idAuto are returned by RETURNING in Postgres and SQLite 3.35 or newer, by OUTPUT INSERTED in
+// SQL Server, and as consecutive ids from LAST_INSERT_ID() in MySQL and SQLDefault, which needs
+// innodb_autoinc_lock_mode 0 or 1. Otherwise, e.g. in Oracle, or if idAuto is empty, ids are nil.
+// The ids are in the order the database returns them: that of rows in MySQL, and of VALUES in
+// Postgres and SQLite, while OUTPUT INSERTED in SQL Server has no guaranteed order.
+//
+func (self *DBI) BulkInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}, idAuto string) ([]int64, error) {
+	if len(columns) == 0 || len(rows) == 0 {
+		return nil, nil
+	}
+	limit, err := placeholderLimit(ctx, self.executor(), self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	size := limit / len(columns)
+	if self.DBType == SQLServer && size > 1000 {
+		size = 1000
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("too many columns for a bulk insert: %d", len(columns))
+	}
+	if self.DBType == SQLite && idAuto != "" {
+		returning, err := sqliteReturning(ctx, self.executor())
+		if err != nil {
+			return nil, err
+		}
+		if !returning {
+			idAuto = ""
+		}
+	}
+
+	run := func(db Executor) ([]int64, error) {
+		var ids []int64
+		for start := 0; start < len(rows); start += size {
+			end := start + size
+			if end > len(rows) {
+				end = len(rows)
+			}
+			var values []interface{}
+			for _, row := range rows[start:end] {
+				if len(row) != len(columns) {
+					return nil, fmt.Errorf("row of %d values for %d columns", len(row), len(columns))
+				}
+				values = append(values, row...)
+			}
+			query := bulkStatement(table, columns, end-start, idAuto, self.DBType)
+
+			switch {
+			case idAuto != "" && (self.DBType == Postgres || self.DBType == SQLite || self.DBType == SQLServer):
+				lists := make([]map[string]interface{}, 0)
+				dbi := &DBI{Executor: db}
+				if err := dbi.SelectSQLContext(ctx, &lists, query, []interface{}{[2]string{idAuto, "int64"}}, values...); err != nil {
+					return nil, err
+				}
+				for _, item := range lists {
+					ids = append(ids, item[idAuto].(int64))
+				}
+			case idAuto != "" && (self.DBType == MySQL || self.DBType == SQLDefault):
+				res, err := db.ExecContext(ctx, query, values...)
+				if err != nil {
+					return nil, err
+				}
+				first, err := res.LastInsertId()
+				if err != nil {
+					return nil, err
+				}
+				for i := range rows[start:end] {
+					ids = append(ids, first+int64(i))
+				}
+			default:
+				if _, err := db.ExecContext(ctx, query, values...); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if ids != nil {
+			self.LastID = ids[len(ids)-1]
+		}
+		return ids, nil
+	}
+
+	var ids []int64
+	err = runTx(ctx, self.executor(), func(db Executor) error {
+		var err error
+		ids, err = run(db)
+		return err
+	})
+	return ids, err
+}
+
+// bulkInsertContext inserts the rows of field values in one transaction.
+// Consecutive rows of the same columns are inserted together, and IdAuto
+// of each row is set. If the multi-row insert can't return the ids in the
+// order of rows, the rows are inserted one by one as in Insert.
+//
+func (self *Table) bulkInsertContext(ctx context.Context, db Executor, rows []map[string]interface{}) error {
+	return runTx(ctx, db, func(db Executor) error {
+		ordered, err := self.bulkOrdered(ctx, db)
+		if err != nil {
+			return err
+		}
+		if ordered {
+			return self.bulkGroupsContext(ctx, db, rows)
+		}
+		for _, row := range rows {
+			id, err := self.insertHashContext(ctx, db, row)
+			if err != nil {
+				return err
+			}
+			row[self.IdAuto] = id
+		}
+		return nil
+	})
+}
+
+// bulkOrdered tells if the multi-row insert gives IdAuto of each row,
+// or if no id is needed. SQLDefault is taken as MySQL. OUTPUT INSERTED in
+// SQL Server has no order, and Oracle and SQLite before 3.35 return no ids
+// of many rows.
+//
+func (self *Table) bulkOrdered(ctx context.Context, db Executor) (bool, error) {
+	switch self.questionNumber {
+	case MySQL, SQLDefault, Postgres, SQLRaw, TSMillisecond, TSMicrosecond:
+		return true, nil
+	case SQLite:
+		if self.IdAuto != "" {
+			return sqliteReturning(ctx, db)
+		}
+	default:
+	}
+	return self.IdAuto == "", nil
+}
+
+func (self *Table) bulkGroupsContext(ctx context.Context, db Executor, rows []map[string]interface{}) error {
+	dbi := &DBI{Executor: db, DBType: self.questionNumber}
+	idAuto := self.IdAuto
+	if self.questionNumber == TSMillisecond || self.questionNumber == TSMicrosecond || self.questionNumber == SQLRaw {
+		idAuto = ""
+	}
+
+	for start := 0; start < len(rows); {
+		columns := fieldNames(rows[start])
+		signature := strings.Join(columns, ",")
+		end := start + 1
+		for end < len(rows) && strings.Join(fieldNames(rows[end]), ",") == signature {
+			end++
+		}
+
+		if self.IdAuto != "" && (self.questionNumber == TSMillisecond || self.questionNumber == TSMicrosecond) {
+			unit := int64(time.Millisecond)
+			if self.questionNumber == TSMicrosecond {
+				unit = int64(time.Microsecond)
+			}
+			first := time.Now().UnixNano() / unit
+			for i, row := range rows[start:end] {
+				row[self.IdAuto] = first + int64(i)
+			}
+			columns = append(columns, self.IdAuto)
+		}
+
+		var values [][]interface{}
+		for _, row := range rows[start:end] {
+			var vs []interface{}
+			for _, column := range columns {
+				vs = append(vs, row[column])
+			}
+			values = append(values, vs)
+		}
+		ids, err := dbi.BulkInsertContext(ctx, self.TableName, columns, values, idAuto)
+		if err != nil {
+			return err
+		}
+		if ids != nil {
+			if len(ids) != end-start {
+				return fmt.Errorf("%d ids returned for %d rows", len(ids), end-start)
+			}
+			for i, row := range rows[start:end] {
+				row[self.IdAuto] = ids[i]
+			}
+		}
+		start = end
+	}
+	return nil
+}
+
+// fieldNames returns the sorted columns of non-nil values.
+//
+func fieldNames(fv map[string]interface{}) []string {
+	var names []string
+	for k, v := range fv {
+		if v != nil {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunAction inserts the row in ARGS, the same as Insert.
+//
+func (self *BulkInsert) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
+}
+
+// RunActionContext inserts the row in ARGS, the same as Insert.
+//
+func (self *BulkInsert) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	return self.RunBulkContext(ctx, db, t, []map[string]interface{}{ARGS}, extra...)
+}
+
+// RunBulkContext inserts all rows in ARGSs. Any value defined in 'extra'
+// will override that key in the row. 'extra' is either one map for all
+// rows, or one map per row, in the order of ARGSs.
+//
+func (self *BulkInsert) RunBulkContext(ctx context.Context, db Executor, t *Table, ARGSs []map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	for i, ARGS := range ARGSs {
+		var ex []map[string]interface{}
+		if len(extra) == len(ARGSs) {
+			ex = extra[i : i+1]
+		} else if hasValue(extra) {
+			ex = extra[:1]
+		}
+		ARGS, err := t.validate(ARGS, self.IsDo, ex...)
+		if err != nil {
+			return nil, err
+		}
+		fieldValues := t.getFv(ARGS)
+		if hasValue(ex) && hasValue(ex[0]) {
+			for key, value := range ex[0] {
+				for _, col := range t.Columns {
+					if col.ColumnName == key {
+						fieldValues[key] = value
+						break
+					}
+				}
+			}
+		}
+		if len(fieldValues) == 0 {
+			return nil, newError(ErrValidation, "", "no data to insert")
+		}
+		rows = append(rows, fieldValues)
+	}
+
+	if err := t.bulkInsertContext(ctx, db, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,148 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// execRecorder records the statements run, without a database, and gives
+// 'first' as the last insert id, as MySQL does for a multi-row insert.
+//
+type execRecorder struct {
+	Executor
+	first   int64
+	queries []string
+}
+
+type execResult int64
+
+func (self execResult) LastInsertId() (int64, error) { return int64(self), nil }
+
+func (self execResult) RowsAffected() (int64, error) { return 1, nil }
+
+func (self *execRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	self.queries = append(self.queries, query)
+	return execResult(self.first), nil
+}
+
+func TestBulkStatement(t *testing.T) {
+	columns := []string{"x", "y"}
+	for dbType, str := range map[DBType]string{
+		MySQL:     "INSERT INTO m_a (x, y) VALUES (?,?),(?,?)",
+		SQLite:    "INSERT INTO m_a (x, y) VALUES (?,?),(?,?) RETURNING id",
+		Postgres:  "INSERT INTO m_a (x, y) VALUES ($1,$2),($3,$4) RETURNING id",
+		SQLServer: "INSERT INTO [m_a] ([x], [y]) OUTPUT INSERTED.[id] VALUES (@p1,@p2),(@p3,@p4)",
+		Oracle:    "INSERT ALL\nINTO \"M_A\" (\"X\", \"Y\") VALUES (:1,:2)\nINTO \"M_A\" (\"X\", \"Y\") VALUES (:3,:4)\nSELECT 1 FROM DUAL",
+	} {
+		if s := bulkStatement("m_a", columns, 2, "id", dbType); s != str {
+			t.Errorf("%d: %s", dbType, s)
+		}
+	}
+	if s := bulkStatement("m_a", columns, 1, "", Postgres); s != "INSERT INTO m_a (x, y) VALUES ($1,$2)" {
+		t.Errorf("%s", s)
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8))`))
+
+	// more rows than one chunk
+	limit, err := placeholderLimit(ctx, db, testDBType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := limit/2 + 10
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{"x", "y"}
+	}
+	dbi := &DBI{DB: db, DBType: testDBType}
+	ids, err := dbi.BulkInsertContext(ctx, "m_a", []string{"x", "y"}, rows, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != n || ids[0] != 1 || ids[n-1] != int64(n) || dbi.LastID != int64(n) {
+		t.Errorf("%d %d %d", len(ids), ids[0], ids[n-1])
+	}
+	db.Exec(`DELETE FROM m_a`)
+
+	str := `{
+    "tableName":"m_a",
+    "pks":["id"],
+    "idAuto":"id",
+	"columns": [
+{"columnName":"x", "label":"x", "typeName":"string", "notnull":true },
+{"columnName":"y", "label":"y", "typeName":"string", "notnull":true },
+{"columnName":"z", "label":"z", "typeName":"string" },
+{"columnName":"id", "label":"id", "typeName":"int", "auto":true }
+	],
+	"actions": [{"actionName": "bulkinsert"}, {"actionName": "topics"}]
+}`
+	model, err := NewModelJson([]byte(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetQuestionNumber(testDBType)
+
+	argss := []map[string]interface{}{{"x": "a", "y": "b"}, {"x": "c", "y": "d"}, {"x": "e", "y": "f", "z": "g"}}
+	lists, err := model.RunModelContext(ctx, db, "bulkinsert", argss, map[string]interface{}{"y": "yy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 3 || lists[2]["z"] != "g" || lists[1]["y"] != "yy" {
+		t.Errorf("%#v", lists)
+	}
+	for i, item := range lists {
+		if item["id"].(int64) <= 0 || (i > 0 && item["id"].(int64) <= lists[i-1]["id"].(int64)) {
+			t.Errorf("%#v", lists)
+		}
+	}
+	if lists, err = model.RunModelContext(ctx, db, "topics", map[string]interface{}{}); err != nil || len(lists) != 3 {
+		t.Errorf("%#v %v", lists, err)
+	}
+
+	// SQLDefault is taken as MySQL, with the ids counted from the first
+	model.SetQuestionNumber(SQLDefault)
+	exec := &execRecorder{first: 10}
+	lists, err = model.RunModelContext(ctx, exec, "bulkinsert", []map[string]interface{}{{"x": "h", "y": "i"}, {"x": "j", "y": "k"}})
+	if err != nil || len(exec.queries) != 1 || len(lists) != 2 || lists[0]["id"] != int64(10) || lists[1]["id"] != int64(11) {
+		t.Errorf("%#v %v %v", lists, exec.queries, err)
+	}
+	model.SetQuestionNumber(testDBType)
+
+	// the extra returned by the before hooks of a row applies to the row only
+	err = model.AddBefore("bulkinsert", func(ctx context.Context, db Executor, t *Table, action string, ARGS, extra map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+		if extra == nil {
+			extra = map[string]interface{}{}
+		}
+		if extra["z"] != nil {
+			return nil, nil, errors.New("extra of another row")
+		}
+		extra["z"] = ARGS["x"].(string) + "z"
+		return ARGS, extra, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, err = model.RunModelContext(ctx, db, "bulkinsert", []map[string]interface{}{{"x": "l", "y": "m"}, {"x": "n", "y": "o"}}, map[string]interface{}{"y": "p"})
+	if err != nil || len(lists) != 2 || lists[0]["z"] != "lz" || lists[1]["z"] != "nz" || lists[0]["y"] != "p" || lists[1]["y"] != "p" {
+		t.Errorf("%#v %v", lists, err)
+	}
+
+	if _, err = model.RunModelContext(ctx, db, "bulkinsert", []map[string]interface{}{{"x": "h"}}); err == nil {
+		t.Errorf("missing y expected to fail")
+	}
+
+	db.Exec(`drop table if exists m_a`)
+}
@@ -0,0 +1,13 @@
+module github.com/genelet/godbi/cmd/godbi
+
+go 1.20
+
+require (
+	github.com/genelet/godbi v0.0.0
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+)
+
+// the command is built with the library in the same checkout
+replace github.com/genelet/godbi => ../..
@@ -0,0 +1,79 @@
+// Command godbi reads the schema of a live database and emits the model
+// and graph JSON of godbi, with the default CRUD actions and nextpages
+// wired from the foreign keys.
+//
+//	godbi -driver mysql -dsn "user:pass@/dbname" [-dir path] [table ...]
+//
+// Without -dir, the graph JSON is printed to the standard output. With -dir,
+// each model is written into 'table.json' and the graph into 'graph.json'.
+//
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/genelet/godbi"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+var dbTypes = map[string]godbi.DBType{
+	"mysql":    godbi.MySQL,
+	"postgres": godbi.Postgres,
+	"sqlite3":  godbi.SQLite,
+}
+
+func main() {
+	driver := flag.String("driver", "mysql", "database driver: mysql, postgres or sqlite3")
+	dsn := flag.String("dsn", "", "data source name of the database")
+	dir := flag.String("dir", "", "directory to write the model and graph files")
+	flag.Parse()
+
+	if err := run(*driver, *dsn, *dir, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(driver, dsn, dir string, tables []string) error {
+	dbType, ok := dbTypes[driver]
+	if !ok {
+		return fmt.Errorf("driver %s not supported", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ts, err := godbi.IntrospectContext(context.Background(), db, dbType, tables...)
+	if err != nil {
+		return err
+	}
+	graph := godbi.DefaultGraph(ts)
+	if dir == "" {
+		return json.NewEncoder(os.Stdout).Encode(graph)
+	}
+
+	for _, model := range graph.Models {
+		if err = writeJSON(filepath.Join(dir, model.GetTable().TableName+".json"), model); err != nil {
+			return err
+		}
+	}
+	return writeJSON(filepath.Join(dir, "graph.json"), graph)
+}
+
+func writeJSON(fn string, v interface{}) error {
+	dat, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, dat, 0644)
+}
@@ -0,0 +1,8 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,115 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// SetConcurrency lets the read-only prepares, and the read-only nextpages,
+// of an action run at the same time, in at most n goroutines of the graph
+// besides those of the callers, so that the connections of *sql.DB are not
+// exhausted.
+// The outputs are in the same order as if run one by one, and the first
+// error cancels the others through the context.
+//
+// The default, 0, runs them one by one, and so do runs in a transaction,
+// and dry runs.
+// Hooks of the actions should be safe for concurrent use.
+//
+func (self *Graph) SetConcurrency(n int) {
+	if n <= 0 {
+		self.concurrency = nil
+		return
+	}
+	self.concurrency = make(chan struct{}, n)
+
+	// set the defaults now, which are otherwise set by the first run
+	for _, item := range self.Models {
+		model, ok := item.(*Model)
+		if !ok {
+			continue
+		}
+		for _, action := range model.Actions {
+			if obj, ok := action.(interface{ setDefaultElementNames() []string }); ok {
+				obj.setDefaultElementNames()
+			}
+		}
+	}
+}
+
+// concurrent tells if actions can run at the same time on db.
+//
+func (self *Graph) concurrent(db Executor, actions []Capability) bool {
+	if self.concurrency == nil || len(actions) < 2 || singleConn(db) {
+		return false
+	}
+	for _, action := range actions {
+		if action.GetIsDo() {
+			return false
+		}
+	}
+	return true
+}
+
+// singleConn tells if db runs on one connection, which can't run another
+// statement while rows are read, in MySQL and Postgres. A dry run is taken
+// as one, as its steps are recorded in order.
+//
+func singleConn(db Executor) bool {
+	switch db.(type) {
+	case *sql.Tx, *sql.Conn, dryRunner:
+		return true
+	default:
+	}
+	return false
+}
+
+// runTasks runs task for 0 to n-1, each in a new goroutine if the graph
+// has a free slot, otherwise in the current one, so that nested runs never
+// wait for each other. It returns the first error, after which the context
+// of the rest is cancelled.
+//
+func (self *Graph) runTasks(ctx context.Context, n int, task func(context.Context, int) error) error {
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	fail := func(err error) {
+		once.Do(func() {
+			first = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < n; i++ {
+		if cctx.Err() != nil {
+			break
+		}
+		select {
+		case self.concurrency <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-self.concurrency
+					wg.Done()
+				}()
+				if err := task(cctx, i); err != nil {
+					fail(err)
+				}
+			}(i)
+		default:
+			if err := task(cctx, i); err != nil {
+				fail(err)
+			}
+		}
+	}
+	wg.Wait()
+
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
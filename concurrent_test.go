@@ -0,0 +1,112 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrent(t *testing.T) {
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+
+	for _, x := range []string{"a", "b", "c"} {
+		if _, err := db.Exec(`INSERT INTO m_a (x, y) VALUES (?, 'y')`, x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, child := range [][2]interface{}{{1, "c1"}, {1, "c2"}, {2, "c3"}, {3, "c4"}} {
+		if _, err := db.Exec(`INSERT INTO m_b (id, child) VALUES (?, ?)`, child[0], child[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	RegisterAfterHook("concurrent_track", func(ctx context.Context, db Executor, t *Table, action string, lists []map[string]interface{}) ([]map[string]interface{}, error) {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return lists, nil
+	})
+	fail := errors.New("fail")
+	RegisterAfterHook("concurrent_fail", func(ctx context.Context, db Executor, t *Table, action string, lists []map[string]interface{}) ([]map[string]interface{}, error) {
+		return nil, fail
+	})
+
+	graph, err := NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"], "idAuto":"id",
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int", "auto":true}],
+"actions":[{"actionName":"topics", "nextpages":[
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}},
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}, "marker":"first", "dimension":1},
+	{"tableName":"m_a", "actionName":"edit", "relateExtra":{"id":"id"}}]},
+	{"actionName":"edit", "prepares":[
+	{"tableName":"m_b", "actionName":"edit", "relateArgs":{"tid":"tid", "child":"child"}},
+	{"tableName":"m_b", "actionName":"topics", "relateArgs":{"id":"id"}, "relateExtra":{"id":"id"}}]},
+	{"actionName":"failing", "nextpages":[
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}},
+	{"tableName":"m_b", "actionName":"fail", "relateExtra":{"id":"id"}}]}]},
+{"tableName":"m_b", "pks":["tid"], "idAuto":"tid",
+"columns":[{"columnName":"tid", "label":"tid", "typeName":"int", "auto":true}, {"columnName":"child", "label":"child", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "after":["concurrent_track"]}, {"actionName":"edit", "after":["concurrent_track"]}, {"actionName":"fail", "after":["concurrent_fail"]}]}]}`),
+		map[string][]Capability{"m_a": {&Topics{Action: Action{ActionName: "failing"}}}, "m_b": {&Topics{Action: Action{ActionName: "fail"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetQuestionNumber(testDBType)
+
+	sequential, err := graph.RunContext(ctx, db, "m_a", "topics")
+	if err != nil || most != 1 {
+		t.Fatalf("%d %v", most, err)
+	}
+	args := map[string]interface{}{"tid": 3, "id": 2}
+	prepared, err := graph.RunContext(ctx, db, "m_a", "edit", args)
+	if err != nil || len(prepared) != 1 || prepared[0]["x"] != "b" {
+		t.Fatalf("%v %#v", err, prepared)
+	}
+
+	// the same output, in at most 1 more goroutine
+	graph.SetConcurrency(1)
+	most = 0
+	lists, err := graph.RunContext(ctx, db, "m_a", "topics")
+	if err != nil || !reflect.DeepEqual(lists, sequential) {
+		t.Errorf("%v\n%#v\n%#v", err, lists, sequential)
+	}
+	if most != 2 {
+		t.Errorf("%d running at most", most)
+	}
+
+	// prepares, merged in their order
+	most = 0
+	lists, err = graph.RunContext(ctx, db, "m_a", "edit", args)
+	if err != nil || !reflect.DeepEqual(lists, prepared) || most != 2 {
+		t.Errorf("%d %v %#v", most, err, lists)
+	}
+
+	// the first error cancels the rest
+	_, err = graph.RunContext(ctx, db, "m_a", "failing")
+	if !errors.Is(err, fail) {
+		t.Errorf("%v", err)
+	}
+
+	// in a transaction, one by one
+	most = 0
+	lists, err = graph.RunTxContext(ctx, db, "m_a", "topics")
+	if err != nil || !reflect.DeepEqual(lists, sequential) || most != 1 {
+		t.Errorf("%d %v", most, err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
@@ -259,6 +259,17 @@ func mergeMap(args interface{}, item map[string]interface{}) interface{} {
 	return nil
 }
 
+// save saves the outputs of the nextpage into the items of data under
+// Subname, skipping the items of no output.
+//
+func (self *Connection) save(data []map[string]interface{}, outputs []interface{}) {
+	for i, item := range data {
+		if outputs[i] != nil {
+			item[self.Subname()] = outputs[i]
+		}
+	}
+}
+
 func (self *Connection) Shorten(lists []map[string]interface{}) interface{} {
 	if self.Dimension == CONNECTDefault || self.Marker == "" {
 		return lists
@@ -0,0 +1,297 @@
+package godbi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cursor is the decoded form of an opaque keyset cursor: the values of
+// the sort columns and PKs of a row, and the direction to page from it.
+//
+type cursor struct {
+	Direction string        `json:"d"`
+	Values    []interface{} `json:"v"`
+}
+
+func encodeCursor(direction string, values []interface{}) (string, error) {
+	bs, err := json.Marshal(&cursor{direction, values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func decodeCursor(str string, n int) (*cursor, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, newError(ErrValidation, "", "wrong cursor: %v", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(bs)))
+	decoder.UseNumber()
+	c := new(cursor)
+	if err = decoder.Decode(c); err != nil {
+		return nil, newError(ErrValidation, "", "wrong cursor: %v", err)
+	}
+	if (c.Direction != "next" && c.Direction != "prev") || len(c.Values) != n {
+		return nil, newError(ErrValidation, "", "wrong cursor: %s", str)
+	}
+	for i, v := range c.Values {
+		if num, ok := v.(json.Number); ok {
+			if x, err := num.Int64(); err == nil {
+				c.Values[i] = x
+			} else if x, err := num.Float64(); err == nil {
+				c.Values[i] = x
+			}
+		}
+	}
+	return c, nil
+}
+
+// keysetColumns returns the columns to sort by, which always end with
+// the PKs so that a cursor points to exactly one row, the labels of the
+// columns in the output rows, and if each column may be NULL.
+//
+func (self *Topics) keysetColumns(t *Table, ARGS map[string]interface{}) ([]string, []string, []bool, error) {
+	name := ""
+	if hasValue(self.Joints) {
+		name = self.Joints[0].getAlias() + "."
+	}
+
+	var columns []string
+	if v, ok := ARGS[self.SORTBY]; ok && v != nil {
+		if s, ok := v.(string); ok {
+			columns = strings.Split(s, ",")
+		}
+	} else if hasValue(self.Joints) && self.Joints[0].Sortby != "" {
+		columns = strings.Split(self.Joints[0].Sortby, ",")
+	}
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+	}
+	for _, pk := range t.Pks {
+		if !grep(columns, pk) && !grep(columns, name+pk) {
+			columns = append(columns, name+pk)
+		}
+	}
+
+	re := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	var fields []string
+	if v, ok := ARGS[self.FIELDS]; ok {
+		fields, _ = v.([]string)
+	}
+	var labels []string
+	var nullable []bool
+	for _, column := range columns {
+		if !re.MatchString(column) {
+			return nil, nil, nil, newError(ErrValidation, column, "wrong sort column %s", column)
+		}
+		short := column
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			short = column[i+1:]
+		}
+		var found *Col
+		for _, col := range t.Columns {
+			if col.ColumnName == short {
+				found = col
+				break
+			}
+		}
+		if found == nil || found.Label == "" {
+			return nil, nil, nil, newError(ErrValidation, column, "sort column %s not found in table %s", column, t.TableName)
+		}
+		if fields != nil && !grep(fields, short) {
+			return nil, nil, nil, newError(ErrValidation, column, "sort column %s not in fields", column)
+		}
+		labels = append(labels, found.Label)
+		nullable = append(nullable, !found.Notnull && !grep(t.Pks, short))
+	}
+	return columns, labels, nullable, nil
+}
+
+// keysetComparison returns the condition that the row of columns is after
+// (cmp '>') or before (cmp '<') the row of values, where NULL sorts before
+// any value. Without NULL, it is the row comparison.
+//
+func keysetComparison(columns []string, nullable []bool, cmp string, values []interface{}, dbType DBType) (string, []interface{}) {
+	plain := true
+	for i := range columns {
+		if values[i] == nil || (nullable[i] && cmp == "<") {
+			plain = false
+		}
+	}
+	if plain {
+		return rowComparison(columns, cmp, values, dbType)
+	}
+
+	var terms []string
+	var outs []interface{}
+	for i, column := range columns {
+		var parts []string
+		var vs []interface{}
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				parts = append(parts, columns[j]+" IS NULL")
+			} else {
+				parts = append(parts, columns[j]+" = ?")
+				vs = append(vs, values[j])
+			}
+		}
+		switch {
+		case values[i] == nil && cmp == "<":
+			continue // nothing before NULL
+		case values[i] == nil:
+			parts = append(parts, column+" IS NOT NULL")
+		case nullable[i] && cmp == "<":
+			parts = append(parts, "("+column+" < ? OR "+column+" IS NULL)")
+			vs = append(vs, values[i])
+		default:
+			parts = append(parts, column+" "+cmp+" ?")
+			vs = append(vs, values[i])
+		}
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		outs = append(outs, vs...)
+	}
+	if terms == nil {
+		return "1=0", nil
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", outs
+}
+
+// keysetOrder returns the ORDER BY of columns, where NULL sorts first, as
+// in MySQL, SQLite and SQL Server, and explicitly in Postgres and Oracle.
+//
+func keysetOrder(columns []string, nullable []bool, descending bool, dbType DBType) string {
+	var terms []string
+	for i, column := range columns {
+		term := column
+		if descending {
+			term += " DESC"
+		}
+		if nullable[i] && (dbType == Postgres || dbType == Oracle) {
+			if descending {
+				term += " NULLS LAST"
+			} else {
+				term += " NULLS FIRST"
+			}
+		}
+		terms = append(terms, term)
+	}
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// keysetContext runs Topics in keyset (cursor) mode. It pages by
+// 'WHERE (sort, pk) > (?, ?) ORDER BY sort, pk LIMIT n', using the
+// cursor in ARGS, and saves the cursors of the next and previous pages
+// in ARGS, if there are such pages.
+//
+func (self *Topics) keysetContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	sql, labels, table := t.filterPars(ARGS, self.FIELDS, self.Joints)
+	columns, cursorLabels, nullable, err := self.keysetColumns(t, ARGS)
+	if err != nil {
+		return nil, err
+	}
+
+	rowcount := 0
+	switch v := ARGS[self.ROWCOUNT].(type) {
+	case int:
+		rowcount = v
+	case string:
+		rowcount, _ = strconv.Atoi(v)
+	default:
+	}
+
+	var c *cursor
+	if v, ok := ARGS[self.CURSOR]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return nil, newError(ErrValidation, "", "wrong cursor: %#v", v)
+		}
+		if c, err = decodeCursor(s, len(columns)); err != nil {
+			return nil, err
+		}
+	}
+
+	_, reverse := ARGS[self.SORTREVERSE]
+	backward := c != nil && c.Direction == "prev"
+	descending := reverse != backward
+
+	var terms []string
+	var values []interface{}
+	if hasValue(extra) && hasValue(extra[0]) {
+		where, extraValues, err := selectCondition(extra[0], table, t.questionNumber, t.Columns)
+		if err != nil {
+			return nil, err
+		}
+		if where != "" {
+			terms = append(terms, where)
+			values = append(values, extraValues...)
+		}
+	}
+	if c != nil {
+		cmp := ">"
+		if descending {
+			cmp = "<"
+		}
+		where, cursorValues := keysetComparison(quoteNames(columns, t.questionNumber), nullable, cmp, c.Values, t.questionNumber)
+		terms = append(terms, where)
+		values = append(values, cursorValues...)
+	}
+	if terms != nil {
+		sql += "\nWHERE " + strings.Join(terms, " AND ")
+	}
+
+	sql += "\n" + keysetOrder(quoteNames(columns, t.questionNumber), nullable, descending, t.questionNumber)
+	if rowcount > 0 {
+		sql += " " + limitOnly(t.questionNumber, rowcount+1)
+	}
+
+	dbi := &DBI{Executor: db}
+	lists := make([]map[string]interface{}, 0)
+	sql = questionMarker(sql, t.questionNumber)
+	if err = dbi.SelectSQLContext(ctx, &lists, sql, labels, values...); err != nil {
+		return nil, err
+	}
+
+	more := rowcount > 0 && len(lists) > rowcount
+	if more {
+		lists = lists[:rowcount]
+	}
+	if backward {
+		for i, j := 0, len(lists)-1; i < j; i, j = i+1, j-1 {
+			lists[i], lists[j] = lists[j], lists[i]
+		}
+	}
+
+	delete(ARGS, self.NEXTCURSOR)
+	delete(ARGS, self.PREVCURSOR)
+	if len(lists) == 0 {
+		return lists, nil
+	}
+	hasNext, hasPrev := more, c != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	// a sort column missing in the output is NULL, as the columns are in fields
+	rowValues := func(item map[string]interface{}) []interface{} {
+		var vs []interface{}
+		for _, label := range cursorLabels {
+			vs = append(vs, item[label])
+		}
+		return vs
+	}
+	if hasNext {
+		if ARGS[self.NEXTCURSOR], err = encodeCursor("next", rowValues(lists[len(lists)-1])); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if ARGS[self.PREVCURSOR], err = encodeCursor("prev", rowValues(lists[0])); err != nil {
+			return nil, err
+		}
+	}
+	return lists, nil
+}
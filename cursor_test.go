@@ -0,0 +1,204 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	str, err := encodeCursor("next", []interface{}{"abc", 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := decodeCursor(str, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Direction != "next" || c.Values[0] != "abc" || c.Values[1].(int64) != 12 {
+		t.Errorf("%#v", c)
+	}
+	if _, err = decodeCursor(str, 1); err == nil {
+		t.Errorf("wrong length accepted")
+	}
+	if _, err = decodeCursor("!!", 2); err == nil {
+		t.Errorf("wrong cursor accepted")
+	}
+
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, Columns: []*Col{
+		{ColumnName: "x", Label: "xx"},
+		{ColumnName: "id", Label: "id"}}}
+	topics := &Topics{Joints: []*Joint{{TableName: "m_a", Alias: "j"}}}
+	topics.setDefaultElementNames()
+	columns, labels, nullable, err := topics.keysetColumns(table, map[string]interface{}{"sortby": "j.x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 2 || columns[0] != "j.x" || columns[1] != "j.id" || labels[0] != "xx" || labels[1] != "id" || !nullable[0] || nullable[1] {
+		t.Errorf("%#v %#v %#v", columns, labels, nullable)
+	}
+	if _, _, _, err = topics.keysetColumns(table, map[string]interface{}{"sortby": "j.x", "fields": []string{"id"}}); err == nil {
+		t.Errorf("sort column out of fields accepted")
+	}
+
+	// NULL sorts first
+	for _, c := range []struct {
+		cmp    string
+		values []interface{}
+		sql    string
+		n      int
+	}{
+		{">", []interface{}{"a", 1}, "(x, id) > (?, ?)", 2},
+		{"<", []interface{}{"a", 1}, "(((x < ? OR x IS NULL)) OR (x = ? AND id < ?))", 3},
+		{">", []interface{}{nil, 1}, "((x IS NOT NULL) OR (x IS NULL AND id > ?))", 1},
+		{"<", []interface{}{nil, 1}, "((x IS NULL AND id < ?))", 1},
+	} {
+		if sql, values := keysetComparison([]string{"x", "id"}, []bool{true, false}, c.cmp, c.values, MySQL); sql != c.sql || len(values) != c.n {
+			t.Errorf("%s %v", sql, values)
+		}
+	}
+	if order := keysetOrder([]string{"x", "id"}, []bool{true, false}, true, Postgres); order != "ORDER BY x DESC NULLS LAST, id DESC" {
+		t.Errorf("%s", order)
+	}
+	if _, _, _, err = topics.keysetColumns(table, map[string]interface{}{"sortby": "x; drop table m_a"}); err == nil {
+		t.Errorf("wrong sort column accepted")
+	}
+}
+
+func TestTopicsKeyset(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8))`))
+	for _, z := range []string{"c", "a", "b", "a", "c"} {
+		db.Exec(`INSERT INTO m_a (x, y, z) VALUES (?, ?, ?)`, "x", "y", z)
+	}
+
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Columns: []*Col{
+		{ColumnName: "x", TypeName: "string", Label: "x"},
+		{ColumnName: "y", TypeName: "string", Label: "y"},
+		{ColumnName: "z", TypeName: "string", Label: "z"},
+		{ColumnName: "id", TypeName: "int", Label: "id"}}}
+	topics := &Topics{Keyset: true}
+
+	// sorted by z then id: (a,2) (a,4) | (b,3) (c,1) | (c,5)
+	args := map[string]interface{}{"sortby": "z", "rowcount": 2}
+	lists, err := topics.RunActionContext(ctx, db, table, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0]["id"].(int) != 2 || lists[1]["id"].(int) != 4 || args["prevcursor"] != nil || args["nextcursor"] == nil {
+		t.Errorf("%v %v", lists, args)
+	}
+
+	args = map[string]interface{}{"sortby": "z", "rowcount": 2, "cursor": args["nextcursor"]}
+	lists, err = topics.RunActionContext(ctx, db, table, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0]["id"].(int) != 3 || lists[1]["id"].(int) != 1 || args["prevcursor"] == nil || args["nextcursor"] == nil {
+		t.Errorf("%v %v", lists, args)
+	}
+	prev := args["prevcursor"]
+
+	args = map[string]interface{}{"sortby": "z", "rowcount": 2, "cursor": args["nextcursor"]}
+	lists, err = topics.RunActionContext(ctx, db, table, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["id"].(int) != 5 || args["nextcursor"] != nil {
+		t.Errorf("%v %v", lists, args)
+	}
+
+	args = map[string]interface{}{"sortby": "z", "rowcount": 2, "cursor": prev}
+	lists, err = topics.RunActionContext(ctx, db, table, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0]["id"].(int) != 2 || lists[1]["id"].(int) != 4 || args["prevcursor"] != nil || args["nextcursor"] == nil {
+		t.Errorf("%v %v", lists, args)
+	}
+
+	// reversed, with a constraint
+	args = map[string]interface{}{"rowcount": 2, "sortreverse": 1}
+	lists, err = topics.RunActionContext(ctx, db, table, args, map[string]interface{}{"z": map[string]interface{}{"ne": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0]["id"].(int) != 5 || lists[1]["id"].(int) != 4 {
+		t.Errorf("%v %v", lists, args)
+	}
+	args = map[string]interface{}{"rowcount": 2, "sortreverse": 1, "cursor": args["nextcursor"]}
+	lists, err = topics.RunActionContext(ctx, db, table, args, map[string]interface{}{"z": map[string]interface{}{"ne": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0]["id"].(int) != 2 || lists[1]["id"].(int) != 1 || args["nextcursor"] != nil {
+		t.Errorf("%v %v", lists, args)
+	}
+
+	// a graph run gives the cursors in the args of the caller
+	graph, err := NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"], "idAuto":"id",
+"columns":[{"columnName":"z", "label":"z", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int", "auto":true}],
+"actions":[{"actionName":"topics", "keyset":true}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetQuestionNumber(testDBType)
+	args = map[string]interface{}{"sortby": "z", "rowcount": 3}
+	lists, err = graph.RunContext(ctx, db, "m_a", "topics", args)
+	if err != nil || len(lists) != 3 || args["nextcursor"] == nil || args["prevcursor"] != nil {
+		t.Fatalf("%v %v %v", lists, args, err)
+	}
+	args["cursor"] = args["nextcursor"]
+	lists, err = graph.RunContext(ctx, db, "m_a", "topics", args)
+	if err != nil || len(lists) != 2 || lists[0]["id"] != 1 || args["nextcursor"] != nil || args["prevcursor"] == nil {
+		t.Errorf("%v %v %v", lists, args, err)
+	}
+
+	// NULL sorts first, in both directions
+	db.Exec(`INSERT INTO m_a (x, y) VALUES ('x', 'y'), ('x', 'y')`)
+	for reverse, expected := range map[bool][]int{false: {6, 7, 2, 4, 3, 1, 5}, true: {5, 1, 3, 4, 2, 7, 6}} {
+		var ids []int
+		var cursors []interface{}
+		var cursor interface{}
+		for i := 0; i < 4; i++ {
+			args = map[string]interface{}{"sortby": "z", "rowcount": 3, "cursor": cursor}
+			if reverse {
+				args["sortreverse"] = 1
+			}
+			lists, err = topics.RunActionContext(ctx, db, table, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range lists {
+				ids = append(ids, item["id"].(int))
+			}
+			cursors = append(cursors, args["prevcursor"])
+			if cursor = args["nextcursor"]; cursor == nil {
+				break
+			}
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("%v: %v", reverse, ids)
+		}
+		// back from the third page
+		args = map[string]interface{}{"sortby": "z", "rowcount": 3, "cursor": cursors[2]}
+		if reverse {
+			args["sortreverse"] = 1
+		}
+		lists, err = topics.RunActionContext(ctx, db, table, args)
+		if err != nil || len(lists) != 3 || lists[0]["id"].(int) != expected[3] || lists[2]["id"].(int) != expected[5] {
+			t.Errorf("%v: %v %v", reverse, lists, err)
+		}
+	}
+
+	db.Exec(`drop table if exists m_a`)
+}
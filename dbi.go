@@ -5,16 +5,96 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 )
 
-// DBI embeds GO's generic SQL handler and
+// Executor is the common interface of *sql.DB, *sql.Tx and *sql.Conn,
+// on which DBI, Table and all actions run their statements.
+//
+type Executor interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+var (
+	_ Executor = (*sql.DB)(nil)
+	_ Executor = (*sql.Tx)(nil)
+	_ Executor = (*sql.Conn)(nil)
+)
+
+// txBeginner is implemented by *sql.DB and *sql.Conn,
+// i.e. by an Executor not yet in a transaction.
+//
+type txBeginner interface {
+	BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+}
+
+// DBI wraps GO's generic SQL handler and
 // adds few functions for database executions and queries.
 //
 type DBI struct {
-	// Embedding the generic database handle.
+	// the generic database handle
 	*sql.DB
+	// Executor: a *sql.Tx or *sql.Conn to run on instead of the *sql.DB, if set
+	Executor Executor
+	// DBType: the database dialect, used by stored procedures
+	DBType DBType
 	// LastID: the last auto id inserted, if the database provides
 	LastID int64
+	// KeepNull: keep NULL columns in the selected rows as nil
+	KeepNull bool
+	// RawBytes: return BLOB and BYTEA columns as []byte
+	RawBytes bool
+	// NoTrim: do not trim spaces of the selected strings
+	NoTrim bool
+	// Location: the time zone of date, timestamp and timestamptz labels, UTC if nil
+	Location *time.Location
+}
+
+// Label defines a selected column by its key name and data type, like
+// [2]string in 'labels', and the pickup options of the column, which
+// override those of DBI if true.
+//
+type Label struct {
+	Name     string
+	Type     string
+	KeepNull bool
+	RawBytes bool
+	NoTrim   bool
+	// Location: the time zone of times, overriding that of DBI if not nil
+	Location *time.Location
+}
+
+// executor returns the Executor, or the *sql.DB if not set.
+//
+func (self *DBI) executor() Executor {
+	if self.Executor != nil {
+		return self.Executor
+	}
+	return self.DB
+}
+
+// runTx runs fn in a transaction if db can begin it, or directly on db,
+// which is then already a transaction or a connection owned by the caller.
+//
+func runTx(ctx context.Context, db Executor, fn func(Executor) error) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("error original: %w, rollback: %v", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
 // TxSQL is the same as DoSQL, but use transaction
@@ -26,7 +106,11 @@ func (self *DBI) TxSQL(query string, args ...interface{}) error {
 // TxSQLContext is the same as DoSQLContext, but use transaction
 //
 func (self *DBI) TxSQLContext(ctx context.Context, query string, args ...interface{}) error {
-	tx, err := self.DB.Begin()
+	beginner, ok := self.executor().(txBeginner)
+	if !ok { // already in a transaction
+		return self.InsertIDContext(ctx, query, args...)
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -35,7 +119,7 @@ func (self *DBI) TxSQLContext(ctx context.Context, query string, args ...interfa
 	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			return fmt.Errorf("errror original: %v, rollback: %v", err, rollbackErr)
+			return fmt.Errorf("error original: %w, rollback: %v", err, rollbackErr)
 		} else {
 			return err
 		}
@@ -64,7 +148,13 @@ func (self *DBI) InsertSerial(query string, args ...interface{}) error {
 // InsertSerialContext insert a SQL into Postgres table with Serial, only save the last inserted ID
 //
 func (self *DBI) InsertSerialContext(ctx context.Context, query string, args ...interface{}) error {
-	stmt, err := self.DB.PrepareContext(ctx, query)
+	if dry, ok := self.executor().(dryRunner); ok {
+		// in a dry run, the insert is recorded only, with LastID 0
+		dry.skipWrite(ctx, query, args)
+		self.LastID = 0
+		return nil
+	}
+	stmt, err := self.executor().PrepareContext(ctx, query)
 	if err != nil { return err }
 	defer stmt.Close()
 	var lastID int64
@@ -75,6 +165,24 @@ func (self *DBI) InsertSerialContext(ctx context.Context, query string, args ...
 	return nil
 }
 
+// InsertReturning insert a SQL row into Oracle table with RETURNING INTO, only save the last inserted ID
+//
+func (self *DBI) InsertReturning(query string, args ...interface{}) error {
+	return self.InsertReturningContext(context.Background(), query, args...)
+}
+
+// InsertReturningContext insert a SQL row into Oracle table with RETURNING INTO, only save the last inserted ID.
+// The ID is bound as an OUT parameter after args, to the last placeholder of query.
+//
+func (self *DBI) InsertReturningContext(ctx context.Context, query string, args ...interface{}) error {
+	var lastID int64
+	_, err := self.executor().ExecContext(ctx, query, append(args, sql.Out{Dest: &lastID})...)
+	if err != nil { return err }
+	self.LastID = lastID
+
+	return nil
+}
+
 // InsertID executes a SQL the same as DB's Exec, only save the last inserted ID
 //
 func (self *DBI) InsertID(query string, args ...interface{}) error {
@@ -84,7 +192,7 @@ func (self *DBI) InsertID(query string, args ...interface{}) error {
 // InsertIDContext executes a SQL the same as DB's Exec, only save the last inserted ID
 //
 func (self *DBI) InsertIDContext(ctx context.Context, query string, args ...interface{}) error {
-	res, err := self.DB.ExecContext(ctx, query, args...)
+	res, err := self.executor().ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -107,7 +215,7 @@ func (self *DBI) DoSQL(query string, args ...interface{}) error {
 // DoSQLContext executes a SQL the same as DB's Exec, only save the last inserted ID
 //
 func (self *DBI) DoSQLContext(ctx context.Context, query string, args ...interface{}) error {
-	_, err := self.DB.ExecContext(ctx, query, args...)
+	_, err := self.executor().ExecContext(ctx, query, args...)
 	return err
 }
 
@@ -129,10 +237,21 @@ func (self *DBI) DoSQLsContext(ctx context.Context, query string, args ...[]inte
 		return self.DoSQLContext(ctx, query, args[0]...)
 	}
 
-	sth, err := self.DB.PrepareContext(ctx, query)
+	if _, ok := self.executor().(dryRunner); ok {
+		// in a dry run, each row is recorded as a statement
+		for _, once := range args {
+			if err := self.DoSQLContext(ctx, query, once...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sth, err := self.executor().PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
+	defer sth.Close()
 
 	var res sql.Result
 	for _, once := range args {
@@ -147,7 +266,6 @@ func (self *DBI) DoSQLsContext(ctx context.Context, query string, args ...[]inte
 	}
 	self.LastID = lastID
 
-	sth.Close()
 	return nil
 }
 
@@ -181,6 +299,12 @@ func getLabels(labels []interface{}) ([]string, []string) {
 			} else {
 				typeLabels = append(typeLabels, "")
 			}
+		case Label:
+			selectLabels = append(selectLabels, v.Name)
+			typeLabels = append(typeLabels, v.Type)
+		case *Label:
+			selectLabels = append(selectLabels, v.Name)
+			typeLabels = append(typeLabels, v.Type)
 		default:
 			selectLabels = append(selectLabels, vs.(string))
 			typeLabels = append(typeLabels, "")
@@ -190,6 +314,52 @@ func getLabels(labels []interface{}) ([]string, []string) {
 	return selectLabels, typeLabels
 }
 
+// pickOptions returns the pickup options of n selected columns, from
+// those of DBI, overridden by the labels of type Label.
+//
+func (self *DBI) pickOptions(labels []interface{}, n int) []Label {
+	opts := make([]Label, n)
+	for i := range opts {
+		opts[i] = Label{KeepNull: self.KeepNull, RawBytes: self.RawBytes, NoTrim: self.NoTrim, Location: self.Location}
+		if i >= len(labels) {
+			continue
+		}
+		var label *Label
+		switch v := labels[i].(type) {
+		case Label:
+			label = &v
+		case *Label:
+			label = v
+		default:
+			continue
+		}
+		opts[i].KeepNull = opts[i].KeepNull || label.KeepNull
+		opts[i].RawBytes = opts[i].RawBytes || label.RawBytes
+		opts[i].NoTrim = opts[i].NoTrim || label.NoTrim
+		if label.Location != nil {
+			opts[i].Location = label.Location
+		}
+	}
+	return opts
+}
+
+// isBinaryType tells if the database type of a column is binary.
+//
+func isBinaryType(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.Contains(name, "BLOB") || strings.Contains(name, "BINARY") ||
+		name == "BYTEA" || name == "IMAGE" || strings.HasSuffix(name, "RAW")
+}
+
+// trimSpace trims the spaces of str, unless the option asks not to.
+//
+func trimSpace(str string, opt Label) string {
+	if opt.NoTrim {
+		return str
+	}
+	return strings.TrimSpace(str)
+}
+
 // SelectSQL returns queried data 'list' as a slice of maps.
 // The map keys and their data types are pre-defined in 'labels',
 // expressed as a slice of interfaces:
@@ -197,6 +367,12 @@ func getLabels(labels []interface{}) ([]string, []string) {
 //    The data types are determined dynamically by the generic handler.
 // 2) when an interface is a 2-string slice, the first element is the key
 //    and the second the data type in "int64", "int", "string" etc.
+// 3) when an interface is a Label, it is the key, the data type and
+//    the options to keep NULLs, binary data and spaces.
+//
+// A NULL column is not in the map, unless KeepNull is set, and strings
+// are trimmed, unless NoTrim is set. With RawBytes, BLOB and BYTEA
+// columns, and those labelled "[]byte", are returned as []byte.
 //
 func (self *DBI) SelectSQL(lists *[]map[string]interface{}, query string, labels []interface{}, args ...interface{}) error {
 	return self.SelectSQLContext(context.Background(), lists, query, labels, args...)
@@ -211,15 +387,42 @@ func (self *DBI) SelectSQL(lists *[]map[string]interface{}, query string, labels
 //    and the second the data type in "int64", "int", "string" etc.
 //
 func (self *DBI) SelectSQLContext(ctx context.Context, lists *[]map[string]interface{}, query string, labels []interface{}, args ...interface{}) error {
-	rows, err := self.DB.QueryContext(ctx, query, args...)
+	rows, err := self.executor().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return self.pickup(ctx, rows, labels, func(item map[string]interface{}) error {
+		*lists = append(*lists, item)
+		return nil
+	})
+}
+
+// StreamSQL runs the query as SelectSQL, but passes the rows to 'fn' one at
+// a time, instead of collecting them into a slice.
+//
+func (self *DBI) StreamSQL(fn func(map[string]interface{}) error, query string, labels []interface{}, args ...interface{}) error {
+	return self.StreamSQLContext(context.Background(), fn, query, labels, args...)
+}
+
+// StreamSQLContext runs the query as SelectSQLContext, but passes the rows
+// to 'fn' one at a time, so only the current row is kept in memory.
+// It stops at the first error returned by 'fn', or when ctx is done,
+// and returns that error.
+//
+func (self *DBI) StreamSQLContext(ctx context.Context, fn func(map[string]interface{}) error, query string, labels []interface{}, args ...interface{}) error {
+	rows, err := self.executor().QueryContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
 
-	return self.pickup(rows, lists, labels, query)
+	return self.pickup(ctx, rows, labels, fn)
 }
 
-func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels []interface{}, query string) error {
+// pickup scans rows, typed by labels, and passes each as a map to 'fn'.
+//
+func (self *DBI) pickup(ctx context.Context, rows *sql.Rows, labels []interface{}, fn func(map[string]interface{}) error) error {
+	defer rows.Close()
 	selectLabels, typeLabels := getLabels(labels)
 
 	var err error
@@ -230,9 +433,31 @@ func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels
 		typeLabels = make([]string, len(selectLabels))
 	}
 
+	opts := self.pickOptions(labels, len(selectLabels))
+	binary := make([]bool, len(selectLabels))
+	for _, opt := range opts {
+		if !opt.RawBytes {
+			continue
+		}
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+		for i, t := range types {
+			if i < len(binary) {
+				binary[i] = isBinaryType(t.DatabaseTypeName())
+			}
+		}
+		break
+	}
+
 	names := make([]interface{}, len(selectLabels))
 	x := make([]interface{}, len(selectLabels))
 	for i := range selectLabels {
+		if scanner := newTypeScanner(typeLabels[i], opts[i].Location); scanner != nil {
+			x[i] = scanner
+			continue
+		}
 		switch typeLabels[i] {
 		case "int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32", "int64":
 			x[i] = new(sql.NullInt64)
@@ -242,7 +467,13 @@ func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels
 			x[i] = new(sql.NullBool)
 		case "time":
 			x[i] = new(sql.NullTime)
-		case "string", "[]byte":
+		case "[]byte":
+			if opts[i].RawBytes {
+				x[i] = new([]byte)
+			} else {
+				x[i] = new(sql.NullString)
+			}
+		case "string":
 			x[i] = new(sql.NullString)
 		default:
 			x[i] = &names[i]
@@ -250,11 +481,23 @@ func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels
 	}
 
 	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		if err = rows.Scan(x...); err != nil {
 			return err
 		}
 		res := make(map[string]interface{})
 		for j, v := range selectLabels {
+			if opts[j].KeepNull {
+				res[v] = nil
+			}
+			if scanner, ok := x[j].(TypeScanner); ok {
+				if value, ok := scanner.Result(); ok {
+					res[v] = value
+				}
+				continue
+			}
 			switch typeLabels[j] {
 			case "int":
 				x := x[j].(*sql.NullInt64)
@@ -322,9 +565,16 @@ func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels
 					res[v] = x.Time
 				}
 			case "string", "[]byte":
-				x := x[j].(*sql.NullString)
-				if x.Valid {
-					res[v] = strings.TrimSpace(x.String)
+				switch x := x[j].(type) {
+				case *[]byte:
+					if *x != nil {
+						res[v] = *x
+					}
+				case *sql.NullString:
+					if x.Valid {
+						res[v] = trimSpace(x.String, opts[j])
+					}
+				default:
 				}
 			default:
 				name := names[j]
@@ -332,17 +582,22 @@ func (self *DBI) pickup(rows *sql.Rows, lists *[]map[string]interface{}, labels
 				if name != nil {
 					switch val := name.(type) {
 					case []uint8:
-						res[v] = strings.TrimSpace(string(val))
+						if opts[j].RawBytes && binary[j] {
+							res[v] = val
+						} else {
+							res[v] = trimSpace(string(val), opts[j])
+						}
 					case string:
-						res[v] = strings.TrimSpace(val)
+						res[v] = trimSpace(val, opts[j])
 					default:
 					}
 				}
 			}
 		}
-		*lists = append(*lists, res)
+		if err = fn(res); err != nil {
+			return err
+		}
 	}
-	rows.Close()
 	if err := rows.Err(); err != nil && err != sql.ErrNoRows {
 		return err
 	}
@@ -355,16 +610,22 @@ func (self *DBI) GetSQL(res map[string]interface{}, query string, labels []inter
 	return self.GetSQLContext(context.Background(), res, query, labels, args...)
 }
 
-// GetSQLContext returns single row 'res'.
+// GetSQLContext returns single row 'res'. Nil values are skipped,
+// unless KeepNull is set.
 //
 func (self *DBI) GetSQLContext(ctx context.Context, res map[string]interface{}, query string, labels []interface{}, args ...interface{}) error {
 	lists := make([]map[string]interface{}, 0)
 	if err := self.SelectSQLContext(ctx, &lists, query, labels, args...); err != nil {
 		return err
 	}
+	keeps := make(map[string]bool)
+	names, _ := getLabels(labels)
+	for i, opt := range self.pickOptions(labels, len(names)) {
+		keeps[names[i]] = opt.KeepNull
+	}
 	if len(lists) >= 1 {
 		for k, v := range lists[0] {
-			if v != nil {
+			if v != nil || self.KeepNull || keeps[k] {
 				res[k] = v
 			}
 		}
@@ -372,80 +633,158 @@ func (self *DBI) GetSQLContext(ctx context.Context, res map[string]interface{},
 	return nil
 }
 
-func sp(procName string, labels []interface{}, n int) (string, string) {
+// procSQL returns the statement to call the stored procedure 'procName'
+// with 'n' IN parameters, and, for MySQL, the statement to read the OUT
+// session variables named in 'labels'.
+//
+// For MySQL, the OUT parameters are passed as session variables '@name'
+// and read back by 'SELECT @name'. For Postgres, a procedure is run by CALL
+// with NULL for each OUT parameter, which are returned in the result row,
+// while a set-returning function is run by 'SELECT * FROM procName(...)'.
+//
+func (self *DBI) procSQL(procName string, labels []interface{}, n int, isSet bool) (string, string, error) {
 	names, _ := getLabels(labels)
-	strQ := strings.Join(strings.Split(strings.Repeat("?", n), ""), ",")
-	str := procName + "(" + strQ
-	strN := "@" + strings.Join(names, ",@")
-	if names != nil {
-		str += ", " + strN
+	var pars []string
+	for i := 0; i < n; i++ {
+		pars = append(pars, "?")
+	}
+
+	proc := strings.TrimSpace(procName)
+	if len(proc) > 5 && strings.ToUpper(proc[:5]) == "CALL " {
+		proc = strings.TrimSpace(proc[5:])
+	}
+
+	switch self.DBType {
+	case Postgres:
+		if isSet {
+			return questionMarkerNumber("SELECT * FROM " + proc + "(" + strings.Join(pars, ", ") + ")"), "", nil
+		}
+		for range names {
+			pars = append(pars, "NULL")
+		}
+		return questionMarkerNumber("CALL " + proc + "(" + strings.Join(pars, ", ") + ")"), "", nil
+	case SQLite:
+		return "", "", fmt.Errorf("stored procedure not supported in SQLite")
+	case SQLServer, Oracle:
+		return "", "", fmt.Errorf("stored procedure not supported in SQL Server and Oracle")
+	default:
+	}
+
+	if names == nil {
+		return "CALL " + proc + "(" + strings.Join(pars, ", ") + ")", "", nil
+	}
+	for _, name := range names {
+		pars = append(pars, "@"+name)
+	}
+	return "CALL " + proc + "(" + strings.Join(pars, ", ") + ")", "SELECT @" + strings.Join(names, ", @"), nil
+}
+
+// pinned runs 'fn' on a single connection, so that session variables
+// set by one statement are seen by the next.
+//
+func (self *DBI) pinned(ctx context.Context, fn func(*DBI) error) error {
+	db, ok := self.executor().(*sql.DB)
+	if !ok { // already a transaction or a connection
+		return fn(self)
 	}
-	return str + ")", "SELECT " + strN
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// a copy keeps the decoding options, such as KeepNull and Location
+	dbi := *self
+	dbi.Executor = conn
+	return fn(&dbi)
 }
 
 // DoProc runs the stored procedure 'procName' and outputs
 // the OUT data as map whose keys are in 'names'.
 //
-func (self *DBI) doProc(res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
-	return self.doProcContext(context.Background(), res, procName, names, args...)
+func (self *DBI) DoProc(res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
+	return self.DoProcContext(context.Background(), res, procName, names, args...)
 }
 
 // DoProcContext runs the stored procedure 'procName' and outputs
 // the OUT data as map whose keys are in 'names'.
 //
-func (self *DBI) doProcContext(ctx context.Context, res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
-	str, strN := sp(procName, names, len(args))
-	if err := self.DoSQLContext(ctx, str, args...); err != nil {
+func (self *DBI) DoProcContext(ctx context.Context, res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
+	str, strN, err := self.procSQL(procName, names, len(args), false)
+	if err != nil {
 		return err
 	}
-	return self.GetSQLContext(ctx, res, strN, names)
+	if self.DBType == Postgres {
+		return self.GetSQLContext(ctx, res, str, names, args...)
+	}
+	return self.pinned(ctx, func(dbi *DBI) error {
+		if err := dbi.DoSQLContext(ctx, str, args...); err != nil {
+			return err
+		}
+		if strN == "" {
+			return nil
+		}
+		return dbi.GetSQLContext(ctx, res, strN, names)
+	})
 }
 
 // TxProc runs the stored procedure 'procName' in transaction
 // and outputs the OUT data as map whose keys are in 'names'.
 //
-func (self *DBI) txProc(res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
-	return self.txProcContext(context.Background(), res, procName, names, args...)
+func (self *DBI) TxProc(res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
+	return self.TxProcContext(context.Background(), res, procName, names, args...)
 }
 
 // TxProcContext runs the stored procedure 'procName' in transaction
 // and outputs the OUT data as map whose keys are in 'names'.
 //
-func (self *DBI) txProcContext(ctx context.Context, res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
-	str, strN := sp(procName, names, len(args))
-	if err := self.TxSQLContext(ctx, str, args...); err != nil {
+func (self *DBI) TxProcContext(ctx context.Context, res map[string]interface{}, procName string, names []interface{}, args ...interface{}) error {
+	beginner, ok := self.executor().(txBeginner)
+	if !ok { // already in a transaction
+		return self.DoProcContext(ctx, res, procName, names, args...)
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	dbi := *self
+	dbi.Executor = tx
+	if err = dbi.DoProcContext(ctx, res, procName, names, args...); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("error original: %w, rollback: %v", err, rollbackErr)
+		}
 		return err
 	}
-	return self.GetSQLContext(ctx, res, strN, names)
+	return tx.Commit()
 }
 
 // SelectProc runs the stored procedure 'procName'.
 // The result, 'lists', is received as slice of map whose key
 // names and data types are defined in 'labels'.
 //
-func (self *DBI) selectProc(lists *[]map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
-	return self.selectProcContext(context.Background(), lists, procName, labels, args...)
+func (self *DBI) SelectProc(lists *[]map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
+	return self.SelectProcContext(context.Background(), lists, procName, labels, args...)
 }
 
 // SelectProcContext runs the stored procedure 'procName'.
 // The result, 'lists', is received as slice of map whose key
 // names and data types are defined in 'labels'.
 //
-func (self *DBI) selectProcContext(ctx context.Context, lists *[]map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
-	return self.selectDoProcContext(ctx, lists, nil, nil, procName, labels, args...)
+func (self *DBI) SelectProcContext(ctx context.Context, lists *[]map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
+	return self.SelectDoProcContext(ctx, lists, nil, nil, procName, labels, args...)
 }
 
 // GetProc returns single row from stored procedure into 'res'.
 //
-func (self *DBI) getProc(res map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
-	return self.getProcContext(context.Background(), res, procName, labels, args...)
+func (self *DBI) GetProc(res map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
+	return self.GetProcContext(context.Background(), res, procName, labels, args...)
 }
 
 // GetProcContext returns single row from stored procedure into 'res'.
 //
-func (self *DBI) getProcContext(ctx context.Context, res map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
+func (self *DBI) GetProcContext(ctx context.Context, res map[string]interface{}, procName string, labels []interface{}, args ...interface{}) error {
 	lists := make([]map[string]interface{}, 0)
-	if err := self.selectProcContext(ctx, &lists, procName, labels, args...); err != nil {
+	if err := self.SelectProcContext(ctx, &lists, procName, labels, args...); err != nil {
 		return err
 	}
 	if len(lists) >= 1 {
@@ -463,8 +802,8 @@ func (self *DBI) getProcContext(ctx context.Context, res map[string]interface{},
 // types are defined in 'labels'. The OUT data, 'hash', is received as map
 // whose keys are in 'names'.
 //
-func (self *DBI) selectDoProc(lists *[]map[string]interface{}, hash map[string]interface{}, names []interface{}, procName string, labels []interface{}, args ...interface{}) error {
-	return self.selectDoProcContext(context.Background(), lists, hash, names, procName, labels, args...)
+func (self *DBI) SelectDoProc(lists *[]map[string]interface{}, hash map[string]interface{}, names []interface{}, procName string, labels []interface{}, args ...interface{}) error {
+	return self.SelectDoProcContext(context.Background(), lists, hash, names, procName, labels, args...)
 }
 
 // SelectDoProcContext runs the stored procedure 'procName'.
@@ -472,13 +811,40 @@ func (self *DBI) selectDoProc(lists *[]map[string]interface{}, hash map[string]i
 // types are defined in 'labels'. The OUT data, 'hash', is received as map
 // whose keys are in 'names'.
 //
-func (self *DBI) selectDoProcContext(ctx context.Context, lists *[]map[string]interface{}, hash map[string]interface{}, names []interface{}, procName string, labels []interface{}, args ...interface{}) error {
-	str, strN := sp(procName, names, len(args))
-	if err := self.SelectSQLContext(ctx, lists, str, labels, args...); err != nil {
-		return err
-	}
-	if hash == nil {
+// For Postgres, 'procName' is a set-returning function whose OUT parameters
+// are the columns of the result, so 'hash' takes 'names' from the first row.
+//
+func (self *DBI) SelectDoProcContext(ctx context.Context, lists *[]map[string]interface{}, hash map[string]interface{}, names []interface{}, procName string, labels []interface{}, args ...interface{}) error {
+	if self.DBType == Postgres {
+		str, _, err := self.procSQL(procName, nil, len(args), true)
+		if err != nil {
+			return err
+		}
+		if err = self.SelectSQLContext(ctx, lists, str, labels, args...); err != nil {
+			return err
+		}
+		if hash != nil && len(*lists) > 0 {
+			keys, _ := getLabels(names)
+			for _, k := range keys {
+				if v, ok := (*lists)[0][k]; ok {
+					hash[k] = v
+				}
+			}
+		}
 		return nil
 	}
-	return self.GetSQLContext(ctx, hash, strN, names)
+
+	str, strN, err := self.procSQL(procName, names, len(args), true)
+	if err != nil {
+		return err
+	}
+	return self.pinned(ctx, func(dbi *DBI) error {
+		if err := dbi.SelectSQLContext(ctx, lists, str, labels, args...); err != nil {
+			return err
+		}
+		if hash == nil || strN == "" {
+			return nil
+		}
+		return dbi.GetSQLContext(ctx, hash, strN, names)
+	})
 }
@@ -2,14 +2,17 @@ package godbi
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestContextProcedure(t *testing.T) {
 	db, err := getdb()
 	if err != nil {
-		panic(err)
+		skipNoDB(t, err)
 	}
+	skipProcedures(t)
 	dbi := &DBI{DB: db}
 	ctx := context.Background()
 
@@ -21,7 +24,7 @@ func TestContextProcedure(t *testing.T) {
 
 	sql := `call proc_w_resultset`
 	lists := make([]map[string]interface{}, 0)
-	err = dbi.selectProcContext(ctx, &lists, sql, nil)
+	err = dbi.SelectProcContext(ctx, &lists, sql, nil)
 	if err != nil {
 		t.Errorf("Running select procedure failed %v", err)
 	}
@@ -37,7 +40,7 @@ func TestContextProcedure(t *testing.T) {
 	sql = `call proc_w`
 	hash := make(map[string]interface{})
 	lists = make([]map[string]interface{}, 0)
-	err = dbi.selectDoProcContext(ctx, &lists, hash, []interface{}{[2]string{"y0", "int64"}}, sql, nil, "m")
+	err = dbi.SelectDoProcContext(ctx, &lists, hash, []interface{}{[2]string{"y0", "int64"}}, sql, nil, "m")
 	if err != nil {
 		t.Errorf("Running select do procedure failed %v", err)
 	}
@@ -55,3 +58,166 @@ func TestContextProcedure(t *testing.T) {
 	dbi.Exec(`drop table if exists letters`)
 	db.Close()
 }
+
+func TestExecutor(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists letters`)
+	db.Exec(`create table letters(x varchar(1))`)
+
+	// statements in a caller-owned transaction are gone after rollback
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi := &DBI{Executor: tx}
+	if err = dbi.DoSQLsContext(ctx, `insert into letters values (?)`, []interface{}{"m"}, []interface{}{"n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = dbi.TxSQLContext(ctx, `insert into letters values (?)`, "p"); err != nil {
+		t.Fatal(err)
+	}
+	lists := make([]map[string]interface{}, 0)
+	if err = dbi.SelectContext(ctx, &lists, `select x from letters`); err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 3 {
+		t.Errorf("%v", lists)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// statements on a pinned connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi = &DBI{Executor: conn}
+	if err = dbi.TxSQLContext(ctx, `insert into letters values (?)`, "a"); err != nil {
+		t.Fatal(err)
+	}
+	lists = make([]map[string]interface{}, 0)
+	if err = dbi.SelectContext(ctx, &lists, `select x from letters`); err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["x"] != "a" {
+		t.Errorf("%v", lists)
+	}
+	conn.Close()
+
+	// the pinned connection keeps the decoding options
+	dbi = &DBI{DB: db, KeepNull: true, NoTrim: true, Location: time.UTC}
+	err = dbi.pinned(ctx, func(pinned *DBI) error {
+		if pinned.Executor == nil || !pinned.KeepNull || !pinned.NoTrim || pinned.Location != time.UTC {
+			t.Errorf("%#v", pinned)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Exec(`drop table if exists letters`)
+}
+
+func TestProcSQL(t *testing.T) {
+	names := []interface{}{[2]string{"y0", "int64"}, "y1"}
+
+	dbi := &DBI{DBType: MySQL}
+	str, strN, err := dbi.procSQL("proc_w", names, 2, false)
+	if err != nil || str != "CALL proc_w(?, ?, @y0, @y1)" || strN != "SELECT @y0, @y1" {
+		t.Errorf("%s %s %v", str, strN, err)
+	}
+	str, strN, err = dbi.procSQL("call proc_w_resultset", nil, 0, true)
+	if err != nil || str != "CALL proc_w_resultset()" || strN != "" {
+		t.Errorf("%s %s %v", str, strN, err)
+	}
+
+	dbi.DBType = Postgres
+	str, strN, err = dbi.procSQL("proc_w", names, 2, false)
+	if err != nil || str != "CALL proc_w($1, $2, NULL, NULL)" || strN != "" {
+		t.Errorf("%s %s %v", str, strN, err)
+	}
+	str, _, err = dbi.procSQL("fn_w", names, 1, true)
+	if err != nil || str != "SELECT * FROM fn_w($1)" {
+		t.Errorf("%s %v", str, err)
+	}
+
+	dbi.DBType = SQLite
+	if _, _, err = dbi.procSQL("proc_w", names, 2, false); err == nil {
+		t.Errorf("error expected for SQLite")
+	}
+}
+
+func TestPickup(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists pickups`)
+	if _, err = db.Exec(`create table pickups(id int, s varchar(10), b blob, n int)`); err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte{0, ' ', 0xff, ' '}
+	if _, err = db.Exec(`insert into pickups values (1, ' x ', ?, NULL)`, blob); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default trims spaces and drops typed NULLs
+	dbi := &DBI{DB: db}
+	lists := make([]map[string]interface{}, 0)
+	labels := []interface{}{[2]string{"id", "int"}, [2]string{"s", "string"}, "b", [2]string{"n", "int"}}
+	if err = dbi.SelectSQLContext(ctx, &lists, `select id, s, b, n from pickups`, labels); err != nil {
+		t.Fatal(err)
+	}
+	item := lists[0]
+	if _, ok := item["n"]; ok || item["s"] != "x" || item["b"] != string(blob[:3]) {
+		t.Errorf("%#v", item)
+	}
+
+	// the options of DBI
+	dbi = &DBI{DB: db, KeepNull: true, RawBytes: true, NoTrim: true}
+	lists = make([]map[string]interface{}, 0)
+	if err = dbi.SelectSQLContext(ctx, &lists, `select id, s, b, n from pickups`, labels); err != nil {
+		t.Fatal(err)
+	}
+	item = lists[0]
+	if v, ok := item["n"]; !ok || v != nil || item["s"] != " x " || !reflect.DeepEqual(item["b"], blob) {
+		t.Errorf("%#v", item)
+	}
+	res := make(map[string]interface{})
+	if err = dbi.GetSQLContext(ctx, res, `select id, s, b, n from pickups`, labels); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := res["n"]; !ok || v != nil {
+		t.Errorf("%#v", res)
+	}
+
+	// the options of labels
+	dbi = &DBI{DB: db}
+	labels = []interface{}{[2]string{"id", "int"}, Label{Name: "s", Type: "string", NoTrim: true}, Label{Name: "b", Type: "[]byte", RawBytes: true}, &Label{Name: "n", Type: "int", KeepNull: true}}
+	res = make(map[string]interface{})
+	if err = dbi.GetSQLContext(ctx, res, `select id, s, b, n from pickups`, labels); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := res["n"]; !ok || v != nil || res["s"] != " x " || !reflect.DeepEqual(res["b"], blob) || res["id"] != 1 {
+		t.Errorf("%#v", res)
+	}
+
+	// the options of columns
+	col := &Col{ColumnName: "s", Label: "s", TypeName: "string", NoTrim: true}
+	if label, ok := col.selectLabel().(Label); !ok || !label.NoTrim || label.Name != "s" {
+		t.Errorf("%#v", col.selectLabel())
+	}
+
+	db.Exec(`drop table if exists pickups`)
+}
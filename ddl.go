@@ -0,0 +1,244 @@
+package godbi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlTypes maps the label types to the column types of each dialect.
+//
+var sqlTypes = map[string]map[DBType]string{
+	"int":     {MySQL: "INT", Postgres: "INTEGER", SQLite: "INTEGER"},
+	"int8":    {MySQL: "TINYINT", Postgres: "SMALLINT", SQLite: "INTEGER"},
+	"int16":   {MySQL: "SMALLINT", Postgres: "SMALLINT", SQLite: "INTEGER"},
+	"int32":   {MySQL: "INT", Postgres: "INTEGER", SQLite: "INTEGER"},
+	"uint":    {MySQL: "INT UNSIGNED", Postgres: "BIGINT", SQLite: "INTEGER"},
+	"uint8":   {MySQL: "TINYINT UNSIGNED", Postgres: "SMALLINT", SQLite: "INTEGER"},
+	"uint16":  {MySQL: "SMALLINT UNSIGNED", Postgres: "INTEGER", SQLite: "INTEGER"},
+	"uint32":  {MySQL: "INT UNSIGNED", Postgres: "BIGINT", SQLite: "INTEGER"},
+	"int64":   {MySQL: "BIGINT", Postgres: "BIGINT", SQLite: "INTEGER"},
+	"float32": {MySQL: "FLOAT", Postgres: "REAL", SQLite: "REAL"},
+	"float64": {MySQL: "DOUBLE", Postgres: "DOUBLE PRECISION", SQLite: "REAL"},
+	"bool":    {MySQL: "BOOLEAN", Postgres: "BOOLEAN", SQLite: "BOOLEAN"},
+	"time":    {MySQL: "DATETIME", Postgres: "TIMESTAMP", SQLite: "DATETIME"},
+	"string":  {MySQL: "VARCHAR(255)", Postgres: "VARCHAR(255)", SQLite: "TEXT"},
+	"[]byte":  {MySQL: "BLOB", Postgres: "BYTEA", SQLite: "BLOB"},
+
+	"uint64":      {MySQL: "BIGINT UNSIGNED", Postgres: "NUMERIC(20)", SQLite: "INTEGER"},
+	"decimal":     {MySQL: "DECIMAL(38,10)", Postgres: "NUMERIC", SQLite: "NUMERIC"},
+	"big.Rat":     {MySQL: "DECIMAL(38,10)", Postgres: "NUMERIC", SQLite: "NUMERIC"},
+	"uuid":        {MySQL: "CHAR(36)", Postgres: "UUID", SQLite: "TEXT"},
+	"json":        {MySQL: "JSON", Postgres: "JSONB", SQLite: "TEXT"},
+	"date":        {MySQL: "DATE", Postgres: "DATE", SQLite: "DATE"},
+	"timestamp":   {MySQL: "DATETIME", Postgres: "TIMESTAMP", SQLite: "DATETIME"},
+	"timestamptz": {MySQL: "TIMESTAMP", Postgres: "TIMESTAMPTZ", SQLite: "DATETIME"},
+	"[]string":    {MySQL: "JSON", Postgres: "TEXT[]", SQLite: "TEXT"},
+	"[]int64":     {MySQL: "JSON", Postgres: "BIGINT[]", SQLite: "TEXT"},
+	"[]float64":   {MySQL: "JSON", Postgres: "DOUBLE PRECISION[]", SQLite: "TEXT"},
+	"[]bool":      {MySQL: "JSON", Postgres: "BOOLEAN[]", SQLite: "TEXT"},
+}
+
+// ddlDialect returns the dialect whose DDL is used for dbType.
+//
+func ddlDialect(dbType DBType) (DBType, error) {
+	switch dbType {
+	case MySQL, SQLDefault, SQLRaw, TSMillisecond, TSMicrosecond:
+		return MySQL, nil
+	case Postgres, SQLite:
+		return dbType, nil
+	default:
+	}
+	return dbType, fmt.Errorf("DDL not supported for database type %d", dbType)
+}
+
+// columnType returns the column type of col in dialect. A label type is
+// translated, and any other TypeName is taken as the column type itself.
+// A string of a whole Max up to 65535, such as an introspected VARCHAR(n),
+// keeps its length.
+//
+func columnType(col *Col, dialect DBType) string {
+	if (col.TypeName == "string" || col.TypeName == "") && col.Max != nil && *col.Max >= 1 && *col.Max <= 65535 && *col.Max == float64(int64(*col.Max)) {
+		return fmt.Sprintf("VARCHAR(%d)", int64(*col.Max))
+	}
+	if types, ok := sqlTypes[col.TypeName]; ok {
+		return types[dialect]
+	}
+	if col.TypeName == "" {
+		return sqlTypes["string"][dialect]
+	}
+	if strings.ToUpper(col.TypeName) == "VARCHAR" {
+		return "VARCHAR(255)"
+	}
+	return col.TypeName
+}
+
+func (self *Table) isAuto(col *Col) bool {
+	return col.Auto || (self.IdAuto != "" && col.ColumnName == self.IdAuto)
+}
+
+// columnDefinition returns the definition of col in CREATE TABLE.
+//
+func (self *Table) columnDefinition(col *Col, dbType, dialect DBType) string {
+	str := col.ColumnName + " " + columnType(col, dialect)
+	auto := self.isAuto(col) && dbType != TSMillisecond && dbType != TSMicrosecond
+	switch {
+	case auto && dialect == SQLite && len(self.Pks) == 1 && self.Pks[0] == col.ColumnName:
+		return col.ColumnName + " INTEGER PRIMARY KEY AUTOINCREMENT"
+	case auto && dialect == Postgres:
+		if strings.ToUpper(columnType(col, dialect)) == "BIGINT" {
+			str = col.ColumnName + " BIGSERIAL"
+		} else {
+			str = col.ColumnName + " SERIAL"
+		}
+	case auto && dialect == MySQL:
+		str += " NOT NULL AUTO_INCREMENT"
+		return str
+	case (self.IdAuto == col.ColumnName && (dbType == TSMillisecond || dbType == TSMicrosecond)):
+		str = col.ColumnName + " BIGINT"
+	default:
+	}
+	if col.Notnull || grep(self.Pks, col.ColumnName) {
+		str += " NOT NULL"
+	}
+	return str
+}
+
+// CreateTable returns the CREATE TABLE statement of the table in dbType.
+// In SQLite, the foreign keys are defined in the statement as well.
+//
+func (self *Table) CreateTable(dbType DBType) (string, error) {
+	dialect, err := ddlDialect(dbType)
+	if err != nil {
+		return "", err
+	}
+	if self.Columns == nil {
+		return "", fmt.Errorf("columns not defined in %s", self.TableName)
+	}
+
+	var defs []string
+	inlinePk := false
+	for _, col := range self.Columns {
+		def := self.columnDefinition(col, dbType, dialect)
+		if strings.HasSuffix(def, " PRIMARY KEY AUTOINCREMENT") {
+			inlinePk = true
+		}
+		defs = append(defs, def)
+	}
+	if self.Pks != nil && !inlinePk {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(self.Pks, ", ")+")")
+	}
+	if dialect == SQLite {
+		for _, fk := range self.Fks {
+			if fk.FkTable != "" {
+				defs = append(defs, "FOREIGN KEY ("+fk.Column+") REFERENCES "+fk.FkTable+" ("+fk.FkColumn+")")
+			}
+		}
+	}
+
+	return "CREATE TABLE " + self.TableName + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)", nil
+}
+
+// CreateIndexes returns the CREATE INDEX statements of the table in dbType:
+// a unique index on Uniques, and an index on each foreign key column.
+//
+func (self *Table) CreateIndexes(dbType DBType) ([]string, error) {
+	if _, err := ddlDialect(dbType); err != nil {
+		return nil, err
+	}
+
+	var outs []string
+	if self.Uniques != nil {
+		outs = append(outs, "CREATE UNIQUE INDEX "+self.uniqueIndexName()+" ON "+self.TableName+" ("+strings.Join(self.Uniques, ", ")+")")
+	}
+	for _, fk := range self.Fks {
+		if (len(self.Pks) == 1 && self.Pks[0] == fk.Column) || (self.Uniques != nil && self.Uniques[0] == fk.Column) {
+			continue // already the leading column of an index
+		}
+		outs = append(outs, "CREATE INDEX "+self.TableName+"_"+fk.Column+"_idx ON "+self.TableName+" ("+fk.Column+")")
+	}
+	return outs, nil
+}
+
+// uniqueIndexName returns the name of the unique index on Uniques: the
+// live one if introspected, or one derived from the columns.
+//
+func (self *Table) uniqueIndexName() string {
+	if self.uniqueIndex != "" {
+		return self.uniqueIndex
+	}
+	return self.TableName + "_" + strings.Join(self.Uniques, "_") + "_uniq"
+}
+
+// dropUnique returns the statement to drop the unique index on Uniques.
+//
+func (self *Table) dropUnique(dialect DBType) string {
+	name := self.uniqueIndexName()
+	switch {
+	case dialect == MySQL:
+		return "DROP INDEX " + name + " ON " + self.TableName
+	case self.uniqueConstraint:
+		return "ALTER TABLE " + self.TableName + " DROP CONSTRAINT " + name
+	default:
+	}
+	return "DROP INDEX " + name
+}
+
+// ForeignKeys returns the ALTER TABLE statements to add the foreign keys
+// of the table in dbType. It is empty in SQLite, which defines them in
+// CreateTable.
+//
+func (self *Table) ForeignKeys(dbType DBType) ([]string, error) {
+	dialect, err := ddlDialect(dbType)
+	if err != nil || dialect == SQLite {
+		return nil, err
+	}
+
+	var outs []string
+	for _, fk := range self.Fks {
+		if fk.FkTable == "" {
+			continue
+		}
+		outs = append(outs, "ALTER TABLE "+self.TableName+" ADD CONSTRAINT "+self.TableName+"_"+fk.Column+"_fk FOREIGN KEY ("+fk.Column+") REFERENCES "+fk.FkTable+" ("+fk.FkColumn+")")
+	}
+	return outs, nil
+}
+
+// DDL returns all statements to create the table in dbType,
+// i.e. CreateTable, CreateIndexes and ForeignKeys.
+//
+func (self *Table) DDL(dbType DBType) ([]string, error) {
+	return ddl([]*Table{self}, dbType)
+}
+
+// DDL returns the statements to create all tables of the graph in dbType.
+// The tables are created first, then the indexes, and the foreign keys
+// last so that the order of models does not matter.
+//
+func (self *Graph) DDL(dbType DBType) ([]string, error) {
+	var tables []*Table
+	for _, model := range self.Models {
+		tables = append(tables, model.GetTable())
+	}
+	return ddl(tables, dbType)
+}
+
+func ddl(tables []*Table, dbType DBType) ([]string, error) {
+	var creates, indexes, fks []string
+	for _, t := range tables {
+		str, err := t.CreateTable(dbType)
+		if err != nil {
+			return nil, err
+		}
+		creates = append(creates, str)
+		strs, err := t.CreateIndexes(dbType)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, strs...)
+		if strs, err = t.ForeignKeys(dbType); err != nil {
+			return nil, err
+		}
+		fks = append(fks, strs...)
+	}
+	return append(append(creates, indexes...), fks...), nil
+}
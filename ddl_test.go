@@ -0,0 +1,110 @@
+package godbi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDDL(t *testing.T) {
+	table := &Table{TableName: "m_b", Pks: []string{"tid"}, IdAuto: "tid", Columns: []*Col{
+		{ColumnName: "tid", TypeName: "int", Notnull: true, Auto: true},
+		{ColumnName: "child", TypeName: "string"},
+		{ColumnName: "price", TypeName: "DECIMAL(10,2)"},
+		{ColumnName: "id", TypeName: "int", Notnull: true}},
+		Fks:     []*Fk{{FkTable: "m_a", FkColumn: "id", Column: "id"}},
+		Uniques: []string{"child", "id"}}
+
+	strs, err := table.DDL(MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strs) != 4 || strs[0] != `CREATE TABLE m_b (
+	tid INT NOT NULL AUTO_INCREMENT,
+	child VARCHAR(255),
+	price DECIMAL(10,2),
+	id INT NOT NULL,
+	PRIMARY KEY (tid)
+)` || strs[1] != `CREATE UNIQUE INDEX m_b_child_id_uniq ON m_b (child, id)` ||
+		strs[2] != `CREATE INDEX m_b_id_idx ON m_b (id)` ||
+		strs[3] != `ALTER TABLE m_b ADD CONSTRAINT m_b_id_fk FOREIGN KEY (id) REFERENCES m_a (id)` {
+		t.Errorf("%#v", strs)
+	}
+
+	str, err := table.CreateTable(Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str != `CREATE TABLE m_b (
+	tid SERIAL NOT NULL,
+	child VARCHAR(255),
+	price DECIMAL(10,2),
+	id INTEGER NOT NULL,
+	PRIMARY KEY (tid)
+)` {
+		t.Errorf("%s", str)
+	}
+
+	str, err = table.CreateTable(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str != `CREATE TABLE m_b (
+	tid INTEGER PRIMARY KEY AUTOINCREMENT,
+	child TEXT,
+	price DECIMAL(10,2),
+	id INTEGER NOT NULL,
+	FOREIGN KEY (id) REFERENCES m_a (id)
+)` {
+		t.Errorf("%s", str)
+	}
+	if strs, err = table.ForeignKeys(SQLite); err != nil || strs != nil {
+		t.Errorf("%#v %v", strs, err)
+	}
+
+	str, err = table.CreateTable(TSMillisecond)
+	if err != nil || !strings.HasPrefix(str, "CREATE TABLE m_b (\n\ttid BIGINT NOT NULL,\n") {
+		t.Errorf("%s %v", str, err)
+	}
+
+	// a string keeps its whole Max as the length
+	max, big := 8.0, 1e6
+	for col, typ := range map[*Col]string{
+		{ColumnName: "child", TypeName: "string", Max: &max}: "VARCHAR(8)",
+		{ColumnName: "child", Max: &max}:                     "VARCHAR(8)",
+		{ColumnName: "child", TypeName: "string", Max: &big}: "VARCHAR(255)",
+		{ColumnName: "price", TypeName: "float64", Max: &max}: "DOUBLE",
+	} {
+		if str = columnType(col, MySQL); str != typ {
+			t.Errorf("%#v: %s", col, str)
+		}
+	}
+}
+
+func TestGraphDDL(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strs, err := graph.DDL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := opensqlite(t, ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, str := range strs {
+		if _, err := db.Exec(str); err != nil {
+			t.Fatalf("%s: %v", str, err)
+		}
+	}
+
+	tables, err := IntrospectContext(context.Background(), db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0].IdAuto != "id" || len(tables[0].Uniques) != 2 || tables[1].IdAuto != "tid" {
+		t.Errorf("%#v", tables)
+	}
+}
@@ -2,7 +2,6 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"strings"
 )
@@ -11,12 +10,12 @@ type Delecs struct {
 	Action
 }
 
-func (self *Delecs) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Delecs) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
-func (self *Delecs) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
-	dbi := &DBI{DB: db}
+func (self *Delecs) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	dbi := &DBI{Executor: db}
 	lists := make([]map[string]interface{}, 0)
 	if t.Fks == nil {
 		return nil, fmt.Errorf("fks not define in %s", t.TableName)
@@ -32,9 +31,9 @@ func (self *Delecs) RunActionContext(ctx context.Context, db *sql.DB, t *Table,
 		}
 	}
 	if values == nil {
-		return nil, fmt.Errorf("fks valeus not found in %s", t.TableName)
+		return nil, newError(ErrValidation, "", "fks values not found in %s", t.TableName)
 	}
-	if t.questionNumber == Postgres { str = questionMarkerNumber(str) }
+	str = questionMarker(str, t.questionNumber)
 	err := dbi.SelectContext(ctx, &lists, `SELECT ` + strings.Join(t.getKeyColumns(), ", ") + ` FROM ` + t.TableName + ` WHERE ` + str, values...)
 	return lists, err
 }
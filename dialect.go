@@ -0,0 +1,190 @@
+package godbi
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// questionMarker rewrites the '?' placeholders in query into those of
+// dbType: $N in Postgres, @pN in SQL Server and :N in Oracle. Other
+// databases use '?' as is.
+//
+func questionMarker(query string, dbType DBType) string {
+	switch dbType {
+	case Postgres:
+		return questionMarkerNumber(query)
+	case SQLServer:
+		return questionMarkerPrefix(query, "@p")
+	case Oracle:
+		return questionMarkerPrefix(query, ":")
+	default:
+	}
+	return query
+}
+
+// limitOffset returns the paging clause after ORDER BY: LIMIT and OFFSET,
+// or OFFSET and FETCH NEXT in SQL Server and Oracle.
+//
+func limitOffset(dbType DBType, rowcount, offset int) string {
+	switch dbType {
+	case SQLServer, Oracle:
+		return "OFFSET " + strconv.Itoa(offset) + " ROWS FETCH NEXT " + strconv.Itoa(rowcount) + " ROWS ONLY"
+	default:
+	}
+	return "LIMIT " + strconv.Itoa(rowcount) + " OFFSET " + strconv.Itoa(offset)
+}
+
+// limitOnly returns the paging clause of the first rowcount rows.
+//
+func limitOnly(dbType DBType, rowcount int) string {
+	switch dbType {
+	case SQLServer, Oracle:
+		return "OFFSET 0 ROWS FETCH NEXT " + strconv.Itoa(rowcount) + " ROWS ONLY"
+	default:
+	}
+	return "LIMIT " + strconv.Itoa(rowcount)
+}
+
+// rowComparison returns the condition that the row of columns is after
+// (cmp '>') or before (cmp '<') the row of '?' values. SQL Server and
+// Oracle, which do not compare row values, get the expanded form
+// '(a > ?) OR (a = ? AND b > ?)', with the values repeated accordingly.
+//
+func rowComparison(columns []string, cmp string, values []interface{}, dbType DBType) (string, []interface{}) {
+	switch dbType {
+	case SQLServer, Oracle:
+	default:
+		return "(" + strings.Join(columns, ", ") + ") " + cmp + " (" + strings.Join(strings.Split(strings.Repeat("?", len(columns)), ""), ", ") + ")", values
+	}
+
+	var terms []string
+	var outs []interface{}
+	for i, column := range columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[j]+" = ?")
+			outs = append(outs, values[j])
+		}
+		parts = append(parts, column+" "+cmp+" ?")
+		outs = append(outs, values[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", outs
+}
+
+// QuoteIdentifier quotes a table or column name in dbType: `name` in
+// MySQL, [name] in SQL Server, and "name" in the others. A dotted name
+// is quoted part by part.
+//
+func QuoteIdentifier(name string, dbType DBType) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		switch dbType {
+		case MySQL:
+			parts[i] = "`" + strings.Replace(part, "`", "``", -1) + "`"
+		case SQLServer:
+			parts[i] = "[" + strings.Replace(part, "]", "]]", -1) + "]"
+		default:
+			parts[i] = `"` + strings.Replace(part, `"`, `""`, -1) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+var plainNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$#]*(\.[A-Za-z_][A-Za-z0-9_$#]*)*$`)
+
+// quoteName quotes the table or column name in the generated SQL of SQL
+// Server and Oracle, where reserved words, like 'user' and 'date', are
+// often used as names. In Oracle, the quoted name is in upper case, which
+// is how an unquoted name is stored. The name is returned as is in other
+// databases, and if it is not a plain, optionally dotted, identifier.
+//
+func quoteName(name string, dbType DBType) string {
+	switch dbType {
+	case SQLServer, Oracle:
+	default:
+		return name
+	}
+	if !plainNameRegexp.MatchString(name) {
+		return name
+	}
+	if dbType == Oracle {
+		name = strings.ToUpper(name)
+	}
+	return QuoteIdentifier(name, dbType)
+}
+
+// quoteNames quotes each name by quoteName.
+//
+func quoteNames(names []string, dbType DBType) []string {
+	outs := make([]string, len(names))
+	for i, name := range names {
+		outs[i] = quoteName(name, dbType)
+	}
+	return outs
+}
+
+// quoteList quotes each name in a comma-separated list, such as the
+// columns of ORDER BY, by quoteName. A name may be followed by ASC or DESC.
+//
+func quoteList(list string, dbType DBType) string {
+	if dbType != SQLServer && dbType != Oracle {
+		return list
+	}
+	names := strings.Split(list, ",")
+	for i, name := range names {
+		parts := strings.SplitN(strings.TrimSpace(name), " ", 2)
+		parts[0] = quoteName(parts[0], dbType)
+		names[i] = strings.Join(parts, " ")
+	}
+	return strings.Join(names, ", ")
+}
+
+// sqliteVersion is the version of the SQLite library, which is linked
+// once in a process, so it is read only on the first need.
+//
+var sqliteVersion struct {
+	sync.Mutex
+	major, minor int
+}
+
+// sqliteReturning tells if the SQLite of db supports RETURNING, which
+// is since version 3.35.
+//
+func sqliteReturning(ctx context.Context, db Executor) (bool, error) {
+	return sqliteSince(ctx, db, 35)
+}
+
+// sqliteSince tells if the SQLite of db is version 3.minor or newer.
+//
+func sqliteSince(ctx context.Context, db Executor, minor int) (bool, error) {
+	sqliteVersion.Lock()
+	defer sqliteVersion.Unlock()
+	if sqliteVersion.major == 0 {
+		if dry, ok := db.(dryRunner); ok { // not a statement of the plan
+			db = dry.underlying()
+		}
+		var version string
+		if err := db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&version); err != nil {
+			return false, err
+		}
+		major, minor := parseVersion(version)
+		sqliteVersion.major, sqliteVersion.minor = major, minor
+	}
+	return sqliteVersion.major > 3 || (sqliteVersion.major == 3 && sqliteVersion.minor >= minor), nil
+}
+
+// parseVersion returns the major and minor numbers of a version like 3.45.1.
+//
+func parseVersion(version string) (int, int) {
+	parts := strings.SplitN(version, ".", 3)
+	major, _ := strconv.Atoi(parts[0])
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
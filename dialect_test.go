@@ -0,0 +1,81 @@
+package godbi
+
+import (
+	"testing"
+)
+
+func TestDialect(t *testing.T) {
+	query := "SELECT x FROM m_a WHERE id=? AND y IN (?,?)"
+	for dbType, marked := range map[DBType]string{
+		MySQL:     "SELECT x FROM m_a WHERE id=? AND y IN (?,?)",
+		SQLite:    "SELECT x FROM m_a WHERE id=? AND y IN (?,?)",
+		Postgres:  "SELECT x FROM m_a WHERE id=$1 AND y IN ($2,$3)",
+		SQLServer: "SELECT x FROM m_a WHERE id=@p1 AND y IN (@p2,@p3)",
+		Oracle:    "SELECT x FROM m_a WHERE id=:1 AND y IN (:2,:3)",
+	} {
+		if str := questionMarker(query, dbType); str != marked {
+			t.Errorf("%d: %s", dbType, str)
+		}
+	}
+
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id"}
+	for dbType, insert := range map[DBType]string{
+		MySQL:     "INSERT INTO m_a (x, y) VALUES (?,?)",
+		SQLite:    "INSERT INTO m_a (x, y) VALUES (?,?) RETURNING id",
+		Postgres:  "INSERT INTO m_a (x, y) VALUES ($1,$2) RETURNING id",
+		SQLServer: "INSERT INTO [m_a] ([x], [y]) OUTPUT INSERTED.[id] VALUES (@p1,@p2)",
+		Oracle:    `INSERT INTO "M_A" ("X", "Y") VALUES (:1,:2) RETURNING "ID" INTO :3`,
+	} {
+		table.SetQuestionNumber(dbType)
+		if str := table.insertStatement([]string{"x", "y"}); str != insert {
+			t.Errorf("%d: %s", dbType, str)
+		}
+	}
+	table.IdAuto = ""
+	table.SetQuestionNumber(Oracle)
+	if str := table.insertStatement([]string{"x", "y"}); str != `INSERT INTO "M_A" ("X", "Y") VALUES (:1,:2)` {
+		t.Errorf("%s", str)
+	}
+
+	topics := new(Topics)
+	topics.setDefaultElementNames()
+	for dbType, order := range map[DBType]string{
+		MySQL:     "ORDER BY x DESC LIMIT 20 OFFSET 40",
+		Postgres:  "ORDER BY x DESC LIMIT 20 OFFSET 40",
+		SQLServer: "ORDER BY [x] DESC OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY",
+		Oracle:    `ORDER BY "X" DESC OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY`,
+	} {
+		table.SetQuestionNumber(dbType)
+		ARGS := map[string]interface{}{"sortby": "x", "sortreverse": 1, "rowcount": 20, "pageno": 3}
+		if str := topics.orderString(table, ARGS); str != order {
+			t.Errorf("%d: %s", dbType, str)
+		}
+	}
+	if str := limitOnly(SQLServer, 11); str != "OFFSET 0 ROWS FETCH NEXT 11 ROWS ONLY" {
+		t.Errorf("%s", str)
+	}
+
+	where, values := rowComparison([]string{"z", "id"}, ">", []interface{}{"a", 2}, Postgres)
+	if where != "(z, id) > (?, ?)" || len(values) != 2 {
+		t.Errorf("%s %v", where, values)
+	}
+	where, values = rowComparison([]string{"z", "y", "id"}, "<", []interface{}{"a", "b", 2}, SQLServer)
+	if where != "((z < ?) OR (z = ? AND y < ?) OR (z = ? AND y = ? AND id < ?))" ||
+		len(values) != 6 || values[0] != "a" || values[1] != "a" || values[2] != "b" || values[5] != 2 {
+		t.Errorf("%s %v", where, values)
+	}
+
+	for dbType, quoted := range map[DBType]string{
+		MySQL:     "`a`.`my``col`",
+		Postgres:  `"a"."my` + "`" + `col"`,
+		SQLServer: "[a].[my`col]",
+		Oracle:    `"a"."my` + "`" + `col"`,
+	} {
+		if str := QuoteIdentifier("a.my`col", dbType); str != quoted {
+			t.Errorf("%d: %s", dbType, str)
+		}
+	}
+	if str := QuoteIdentifier("order]s", SQLServer); str != "[order]]s]" {
+		t.Errorf("%s", str)
+	}
+}
@@ -2,8 +2,7 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"strings"
 )
 
 type Edit struct {
@@ -19,27 +18,35 @@ func (self *Edit) setDefaultElementNames() []string {
 	return []string{self.FIELDS}
 }
 
-func (self *Edit) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Edit) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
-func (self *Edit) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Edit) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	self.setDefaultElementNames()
 	sql, labels, table := t.filterPars(ARGS, self.FIELDS, self.Joints)
 
 	ids := t.getIdVal(ARGS, extra...)
 	if !hasValue(ids) {
-		return nil, fmt.Errorf("pk value not provided")
+		return nil, newError(ErrValidation, strings.Join(t.Pks, ", "), "pk value not provided")
 	}
 
-	where, extraValues := t.singleCondition(ids, table, extra...)
+	where, extraValues, err := t.singleCondition(ids, table, extra...)
+	if err != nil {
+		return nil, err
+	}
 	if where != "" {
 		sql += "\nWHERE " + where
 	}
 
 	lists := make([]map[string]interface{}, 0)
-	dbi := &DBI{DB: db}
-	if t.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
-	err := dbi.SelectSQLContext(ctx, &lists, sql, labels, extraValues...)
-	return lists, err
+	dbi := &DBI{Executor: db}
+	sql = questionMarker(sql, t.questionNumber)
+	if err = dbi.SelectSQLContext(ctx, &lists, sql, labels, extraValues...); err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, newError(ErrNotFound, strings.Join(t.Pks, ", "), "row not found")
+	}
+	return lists, nil
 }
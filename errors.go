@@ -0,0 +1,179 @@
+package godbi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// The kinds of errors, to be matched by errors.Is on any error returned
+// by Model and Graph runs. ErrUnique is also ErrConflict. ErrNotFound is
+// a row missing in edit, and ErrConfig a model or action missing in the graph.
+//
+var (
+	ErrValidation = errors.New("validation failed")
+	ErrNotFound   = errors.New("not found")
+	ErrConfig     = errors.New("configuration error")
+	ErrConflict   = errors.New("conflict")
+	ErrUnique     = errors.New("unique violation")
+	ErrForeignKey = errors.New("foreign key violation")
+	ErrLimit      = errors.New("limit exceeded")
+)
+
+// Error is a failure of godbi, with its kind and where it happened.
+// Model and Action are set by Model and Graph runs, and Column, if known,
+// is the column of the failure, or the key name of a MySQL violation.
+//
+type Error struct {
+	Kind   error
+	Model  string
+	Action string
+	Column string
+	Err    error
+}
+
+func (self *Error) Error() string {
+	if self.Model == "" {
+		return self.Err.Error()
+	}
+	return self.Model + " " + self.Action + ": " + self.Err.Error()
+}
+
+func (self *Error) Unwrap() error {
+	return self.Err
+}
+
+// Is tells if the error is of kind target.
+//
+func (self *Error) Is(target error) bool {
+	if self.Kind == nil {
+		return false
+	}
+	return target == self.Kind || (target == ErrConflict && self.Kind == ErrUnique)
+}
+
+// Is tells that a ValidationError is ErrValidation.
+//
+func (self *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// newError returns an *Error of kind on column.
+//
+func newError(kind error, column string, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Column: column, Err: fmt.Errorf(format, args...)}
+}
+
+// wrapError wraps err into an *Error of model and action, unless it is
+// already one of an inner model, and finds the kind of a driver error.
+//
+func wrapError(err error, model, action string) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Model == "" {
+			e.Model, e.Action = model, action
+		}
+		return err
+	}
+
+	wrapped := &Error{Model: model, Action: action, Err: err}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		wrapped.Kind = ErrValidation
+		if len(verr.Fields) > 0 {
+			wrapped.Column = verr.Fields[0].Field
+		}
+	} else {
+		wrapped.Kind, wrapped.Column = driverError(err)
+	}
+	return wrapped
+}
+
+var (
+	pgKeyRegexp     = regexp.MustCompile(`Key \(([^)]+)\)=`)
+	mysqlKeyRegexp  = regexp.MustCompile(`for key '([^']+)'`)
+	mysqlFkRegexp   = regexp.MustCompile("FOREIGN KEY \\(`([^`]+)`\\)")
+	sqliteKeyRegexp = regexp.MustCompile(`constraint failed: ([^()]+?)( \(\d+\))?$`)
+)
+
+// driverError returns the kind, ErrUnique or ErrForeignKey, and the column
+// of a constraint violation from the error codes of the MySQL, Postgres
+// and SQLite drivers, read by reflection so that no driver is imported.
+//
+func driverError(err error) (error, string) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		v := reflect.Indirect(reflect.ValueOf(e))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		msg := e.Error()
+
+		// Postgres, by SQLSTATE, in pq and pgx
+		code := ""
+		if state, ok := e.(interface{ SQLState() string }); ok {
+			code = state.SQLState()
+		} else if f := v.FieldByName("Code"); f.IsValid() && f.Kind() == reflect.String {
+			code = f.String()
+		}
+		if code == "23505" || code == "23503" {
+			column := ""
+			if f := v.FieldByName("Detail"); f.IsValid() && f.Kind() == reflect.String {
+				if m := pgKeyRegexp.FindStringSubmatch(f.String()); m != nil {
+					column = m[1]
+				}
+			}
+			if code == "23505" {
+				return ErrUnique, column
+			}
+			return ErrForeignKey, column
+		}
+
+		// MySQL, by error number
+		if f := v.FieldByName("Number"); f.IsValid() && f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uint64 {
+			switch f.Uint() {
+			case 1062, 1586:
+				column := ""
+				if m := mysqlKeyRegexp.FindStringSubmatch(msg); m != nil {
+					column = m[1]
+				}
+				return ErrUnique, column
+			case 1216, 1217, 1451, 1452:
+				column := ""
+				if m := mysqlFkRegexp.FindStringSubmatch(msg); m != nil {
+					column = m[1]
+				}
+				return ErrForeignKey, column
+			default:
+			}
+		}
+
+		// SQLite, by extended result code, in mattn/go-sqlite3 and modernc.org/sqlite
+		extended := int64(-1)
+		if f := v.FieldByName("ExtendedCode"); f.IsValid() && f.Kind() >= reflect.Int && f.Kind() <= reflect.Int64 {
+			extended = f.Int()
+		} else if c, ok := e.(interface{ Code() int }); ok {
+			extended = int64(c.Code())
+		}
+		if extended >= 0 {
+			switch extended {
+			case 2067, 1555:
+				var columns []string
+				if m := sqliteKeyRegexp.FindStringSubmatch(msg); m != nil {
+					for _, name := range strings.Split(m[1], ", ") {
+						columns = append(columns, name[strings.LastIndex(name, ".")+1:])
+					}
+				}
+				return ErrUnique, strings.Join(columns, ", ")
+			case 787:
+				return ErrForeignKey, ""
+			default:
+			}
+		}
+	}
+	return nil, ""
+}
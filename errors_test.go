@@ -0,0 +1,116 @@
+package godbi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type pgError struct {
+	Code   string
+	Detail string
+}
+
+func (self *pgError) Error() string { return "pq: violation" }
+
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (self *mysqlError) Error() string { return self.Message }
+
+func TestDriverError(t *testing.T) {
+	for _, c := range []struct {
+		err    error
+		kind   error
+		column string
+	}{
+		{&pgError{Code: "23505", Detail: "Key (x, y)=(a, b) already exists."}, ErrUnique, "x, y"},
+		{&pgError{Code: "23503", Detail: "Key (id)=(5) is not present in table \"m_a\"."}, ErrForeignKey, "id"},
+		{&mysqlError{Number: 1062, Message: "Duplicate entry 'a-b' for key 'x'"}, ErrUnique, "x"},
+		{&mysqlError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`m_b`, CONSTRAINT `fk` FOREIGN KEY (`id`) REFERENCES `m_a` (`id`))"}, ErrForeignKey, "id"},
+		{fmt.Errorf("wrapped: %w", &mysqlError{Number: 1062, Message: "Duplicate entry"}), ErrUnique, ""},
+		{&mysqlError{Number: 1146, Message: "Table doesn't exist"}, nil, ""},
+		{errors.New("other"), nil, ""},
+	} {
+		kind, column := driverError(c.err)
+		if kind != c.kind || column != c.column {
+			t.Errorf("%v: %v %s", c.err, kind, column)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+	db.Exec(`CREATE UNIQUE INDEX m_a_x_y ON m_a (x, y)`)
+
+	args := map[string]interface{}{"x": "a", "y": "b"}
+	if _, err = graph.RunContext(ctx, db, "m_a", "insert", args); err != nil {
+		t.Fatal(err)
+	}
+
+	// a unique violation of the driver
+	_, err = graph.RunContext(ctx, db, "m_a", "insert", args)
+	var e *Error
+	if !errors.Is(err, ErrUnique) || !errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) || !errors.As(err, &e) {
+		t.Fatalf("%#v", err)
+	}
+	if e.Model != "m_a" || e.Action != "insert" || (testDBType == SQLite && e.Column != "x, y") {
+		t.Errorf("%#v", e)
+	}
+
+	// a validation error, with the field errors
+	_, err = graph.RunContext(ctx, db, "m_a", "insert", map[string]interface{}{"x": "c"})
+	var verr *ValidationError
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &verr) || !errors.As(err, &e) {
+		t.Fatalf("%#v", err)
+	}
+	if verr.Fields[0].Field != "y" || e.Column != "y" || err.Error() != "m_a insert: validation failed: y: not found in input" {
+		t.Errorf("%v %#v", err, e)
+	}
+
+	// a failure in a nextpage keeps its own model and action
+	graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"insert": map[string]interface{}{"tid": "x"}}}, nil)
+	_, err = graph.RunContext(ctx, db, "m_a", "insert", map[string]interface{}{"x": "d", "y": "e"})
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &e) || e.Model != "m_b" || e.Action != "insert" || e.Column != "tid" {
+		t.Errorf("%#v", err)
+	}
+	graph.Initialize(nil, nil)
+
+	_, err = graph.RunContext(ctx, db, "m_x", "edit")
+	if !errors.Is(err, ErrConfig) || !errors.As(err, &e) || e.Model != "m_x" {
+		t.Errorf("%#v", err)
+	}
+	_, err = graph.RunContext(ctx, db, "m_a", "wrong")
+	if !errors.Is(err, ErrConfig) || errors.Is(err, ErrNotFound) {
+		t.Errorf("%#v", err)
+	}
+
+	// a missing row is an error of edit only, while delete and update of
+	// it are idempotent
+	_, err = graph.RunContext(ctx, db, "m_a", "edit", map[string]interface{}{"id": 999})
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &e) || e.Model != "m_a" || e.Action != "edit" {
+		t.Errorf("%#v", err)
+	}
+	ma := graph.GetModel("m_a").(*Model)
+	ma.Actions = append(ma.Actions, &Update{Action: Action{ActionName: "update", IsDo: true}})
+	for action, args := range map[string]map[string]interface{}{
+		"update": {"id": 999, "x": "a", "y": "b"},
+		"delete": {"id": 999},
+		"topics": {"x": "none"},
+	} {
+		if _, err = graph.RunContext(ctx, db, "m_a", action, args); err != nil {
+			t.Errorf("%s: %v", action, err)
+		}
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
@@ -0,0 +1,187 @@
+package godbi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// filterOperators returns the WHERE statement and its values of operators
+// 'ops' on column 'field', joined by AND. For example,
+// {"gte": 10, "lt": 100} gives "field >= ? AND field < ?".
+//
+// Operator | SQL
+// -------- | ---
+// eq, ne, gt, gte, lt, lte | =, !=, >, >=, <, <=
+// like, nlike | LIKE, NOT LIKE
+// ilike | ILIKE in Postgres, or LOWER(field) LIKE LOWER(?)
+// in, nin | IN, NOT IN, on a slice
+// between | BETWEEN ? AND ?, on a slice of 2 values
+// null | IS NULL if true, IS NOT NULL if false
+//
+func filterOperators(field string, ops map[string]interface{}, dbType DBType) (string, []interface{}, error) {
+	name := quoteName(field, dbType)
+	var keys []string
+	for op := range ops {
+		keys = append(keys, op)
+	}
+	sort.Strings(keys)
+
+	var terms []string
+	var values []interface{}
+	for _, op := range keys {
+		value := ops[op]
+		switch op {
+		case "eq", "ne", "gt", "gte", "lt", "lte", "like", "nlike":
+			terms = append(terms, name+" "+comparisons[op]+" ?")
+			values = append(values, value)
+		case "ilike":
+			if dbType == Postgres {
+				terms = append(terms, name+" ILIKE ?")
+			} else {
+				terms = append(terms, "LOWER("+name+") LIKE LOWER(?)")
+			}
+			values = append(values, value)
+		case "in", "nin":
+			vs, ok := toSlice(value)
+			if !ok || len(vs) == 0 {
+				return "", nil, newError(ErrValidation, field, "operator %s on %s needs a non-empty list", op, field)
+			}
+			not := ""
+			if op == "nin" {
+				not = "NOT "
+			}
+			terms = append(terms, name+" "+not+"IN ("+strings.Join(strings.Split(strings.Repeat("?", len(vs)), ""), ",")+")")
+			values = append(values, vs...)
+		case "between":
+			vs, ok := toSlice(value)
+			if !ok || len(vs) != 2 {
+				return "", nil, newError(ErrValidation, field, "operator between on %s needs 2 values", field)
+			}
+			terms = append(terms, name+" BETWEEN ? AND ?")
+			values = append(values, vs...)
+		case "null":
+			isNull, ok := value.(bool)
+			if !ok {
+				return "", nil, newError(ErrValidation, field, "operator null on %s needs a boolean", field)
+			}
+			if isNull {
+				terms = append(terms, name+" IS NULL")
+			} else {
+				terms = append(terms, name+" IS NOT NULL")
+			}
+		default:
+			return "", nil, newError(ErrValidation, field, "operator %s on %s not supported", op, field)
+		}
+	}
+	if terms == nil {
+		return "", nil, newError(ErrValidation, field, "no operator found on %s", field)
+	}
+
+	return strings.Join(terms, " AND "), values, nil
+}
+
+var comparisons = map[string]string{
+	"eq":    "=",
+	"ne":    "!=",
+	"gt":    ">",
+	"gte":   ">=",
+	"lt":    "<",
+	"lte":   "<=",
+	"like":  "LIKE",
+	"nlike": "NOT LIKE",
+}
+
+// orCondition returns the statement of '$or', whose value is a slice of
+// maps, each compiled by selectCondition and joined by OR. Raw SQL
+// statements are refused in the groups.
+//
+func orCondition(value interface{}, table string, dbType DBType, cols []*Col) (string, []interface{}, error) {
+	var groups []map[string]interface{}
+	switch t := value.(type) {
+	case []map[string]interface{}:
+		groups = t
+	case []interface{}:
+		for _, item := range t {
+			group, ok := item.(map[string]interface{})
+			if !ok {
+				return "", nil, newError(ErrValidation, "$or", "wrong $or element: %#v", item)
+			}
+			groups = append(groups, group)
+		}
+	default:
+		return "", nil, newError(ErrValidation, "$or", "wrong $or value: %#v", value)
+	}
+
+	var terms []string
+	var values []interface{}
+	for _, group := range groups {
+		for field, v := range group {
+			if isRawSQL(field, v) {
+				return "", nil, newError(ErrValidation, field, "raw SQL %s not allowed in $or", field)
+			}
+		}
+		s, vs, err := selectCondition(group, table, dbType, cols)
+		if err != nil {
+			return "", nil, err
+		}
+		if s != "" {
+			terms = append(terms, "("+s+")")
+			values = append(values, vs...)
+		}
+	}
+	return strings.Join(terms, " OR "), values, nil
+}
+
+// isRawSQL tells if 'field' of 'value' is a raw SQL statement, i.e.
+// a string of key ending with '_gsql'.
+//
+func isRawSQL(field string, value interface{}) bool {
+	_, ok := value.(string)
+	return ok && strings.HasSuffix(field, "_gsql")
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// knownColumn tells if 'field', optionally prefixed by a table name or
+// alias, is a column in 'cols'. Any field is known if 'cols' is empty.
+//
+func knownColumn(field string, cols []*Col) bool {
+	if len(cols) == 0 {
+		return true
+	}
+	name := field
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		if !identifierRegexp.MatchString(field[:i]) {
+			return false
+		}
+		name = field[i+1:]
+	}
+	for _, col := range cols {
+		if col.ColumnName == field || col.ColumnName == name || strings.HasSuffix(col.ColumnName, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSlice returns the elements if 'value' is a slice, except []byte.
+//
+func toSlice(value interface{}) ([]interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if vs, ok := value.([]interface{}); ok {
+		return vs, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	vs := make([]interface{}, rv.Len())
+	for i := range vs {
+		vs[i] = rv.Index(i).Interface()
+	}
+	return vs, true
+}
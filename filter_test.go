@@ -0,0 +1,84 @@
+package godbi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFilterOperators(t *testing.T) {
+	cols := []*Col{{ColumnName: "x"}, {ColumnName: "y"}, {ColumnName: "id"}}
+	for _, c := range []struct {
+		extra  map[string]interface{}
+		sql    map[DBType]string
+		values []interface{}
+	}{
+		{map[string]interface{}{"x": "a"}, map[DBType]string{
+			MySQL: "(m_a.x =?)", Postgres: "(m_a.x =$1)", SQLServer: "([m_a].[x] =@p1)", Oracle: `("M_A"."X" =:1)`}, []interface{}{"a"}},
+		{map[string]interface{}{"id": 1}, map[DBType]string{
+			MySQL: "(m_a.id =?)", Postgres: "(m_a.id =$1)", SQLServer: "([m_a].[id] =@p1)", Oracle: `("M_A"."ID" =:1)`}, []interface{}{1}},
+		{map[string]interface{}{"id": []int{1, 2}}, map[DBType]string{
+			MySQL: "(m_a.id IN (?,?))", Postgres: "(m_a.id IN ($1,$2))", SQLServer: "([m_a].[id] IN (@p1,@p2))", Oracle: `("M_A"."ID" IN (:1,:2))`}, []interface{}{1, 2}},
+		{map[string]interface{}{"id": map[string]interface{}{"eq": 1, "ne": 2}}, map[DBType]string{
+			MySQL: "(m_a.id = ? AND m_a.id != ?)", Postgres: "(m_a.id = $1 AND m_a.id != $2)", SQLServer: "([m_a].[id] = @p1 AND [m_a].[id] != @p2)", Oracle: `("M_A"."ID" = :1 AND "M_A"."ID" != :2)`}, []interface{}{1, 2}},
+		{map[string]interface{}{"id": map[string]interface{}{"gt": 1, "gte": 2, "lt": 9, "lte": 8}}, map[DBType]string{
+			MySQL: "(m_a.id > ? AND m_a.id >= ? AND m_a.id < ? AND m_a.id <= ?)", Postgres: "(m_a.id > $1 AND m_a.id >= $2 AND m_a.id < $3 AND m_a.id <= $4)", SQLServer: "([m_a].[id] > @p1 AND [m_a].[id] >= @p2 AND [m_a].[id] < @p3 AND [m_a].[id] <= @p4)", Oracle: `("M_A"."ID" > :1 AND "M_A"."ID" >= :2 AND "M_A"."ID" < :3 AND "M_A"."ID" <= :4)`}, []interface{}{1, 2, 9, 8}},
+		{map[string]interface{}{"x": map[string]interface{}{"like": "a%", "nlike": "%b"}}, map[DBType]string{
+			MySQL: "(m_a.x LIKE ? AND m_a.x NOT LIKE ?)", Postgres: "(m_a.x LIKE $1 AND m_a.x NOT LIKE $2)", SQLServer: "([m_a].[x] LIKE @p1 AND [m_a].[x] NOT LIKE @p2)", Oracle: `("M_A"."X" LIKE :1 AND "M_A"."X" NOT LIKE :2)`}, []interface{}{"a%", "%b"}},
+		{map[string]interface{}{"x": map[string]interface{}{"ilike": "A%"}}, map[DBType]string{
+			MySQL: "(LOWER(m_a.x) LIKE LOWER(?))", SQLite: "(LOWER(m_a.x) LIKE LOWER(?))", Postgres: "(m_a.x ILIKE $1)", SQLServer: "(LOWER([m_a].[x]) LIKE LOWER(@p1))", Oracle: `(LOWER("M_A"."X") LIKE LOWER(:1))`}, []interface{}{"A%"}},
+		{map[string]interface{}{"id": map[string]interface{}{"in": []interface{}{1, 2}, "nin": []int{3}}}, map[DBType]string{
+			MySQL: "(m_a.id IN (?,?) AND m_a.id NOT IN (?))", Postgres: "(m_a.id IN ($1,$2) AND m_a.id NOT IN ($3))", SQLServer: "([m_a].[id] IN (@p1,@p2) AND [m_a].[id] NOT IN (@p3))", Oracle: `("M_A"."ID" IN (:1,:2) AND "M_A"."ID" NOT IN (:3))`}, []interface{}{1, 2, 3}},
+		{map[string]interface{}{"id": map[string]interface{}{"between": []int{1, 5}}}, map[DBType]string{
+			MySQL: "(m_a.id BETWEEN ? AND ?)", Postgres: "(m_a.id BETWEEN $1 AND $2)", SQLServer: "([m_a].[id] BETWEEN @p1 AND @p2)", Oracle: `("M_A"."ID" BETWEEN :1 AND :2)`}, []interface{}{1, 5}},
+		{map[string]interface{}{"x": map[string]interface{}{"null": true}, "y": map[string]interface{}{"null": false}}, map[DBType]string{
+			MySQL: "(m_a.x IS NULL) AND (m_a.y IS NOT NULL)", Oracle: `("M_A"."X" IS NULL) AND ("M_A"."Y" IS NOT NULL)`}, nil},
+		{map[string]interface{}{"y": "b", "$or": []interface{}{map[string]interface{}{"x": "a"}, map[string]interface{}{"id": map[string]interface{}{"gt": 3}}}}, map[DBType]string{
+			MySQL: "(((m_a.x =?)) OR ((m_a.id > ?))) AND (m_a.y =?)", Postgres: "(((m_a.x =$1)) OR ((m_a.id > $2))) AND (m_a.y =$3)", SQLServer: "((([m_a].[x] =@p1)) OR (([m_a].[id] > @p2))) AND ([m_a].[y] =@p3)", Oracle: `((("M_A"."X" =:1)) OR (("M_A"."ID" > :2))) AND ("M_A"."Y" =:3)`}, []interface{}{"a", 3, "b"}},
+		{map[string]interface{}{"m_a.x": "a", "x_gsql": "y IS NOT NULL"}, map[DBType]string{
+			MySQL: "(m_a.x =?) AND (y IS NOT NULL)", Postgres: "(m_a.x =$1) AND (y IS NOT NULL)"}, []interface{}{"a"}},
+	} {
+		for dbType, sql := range c.sql {
+			where, values, err := selectCondition(c.extra, "m_a", dbType, cols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if where = questionMarker(where, dbType); where != sql {
+				t.Errorf("%d: %s", dbType, where)
+			}
+			if !reflect.DeepEqual(values, c.values) {
+				t.Errorf("%d: %v", dbType, values)
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		extra  map[string]interface{}
+		column string
+	}{
+		{map[string]interface{}{"id": map[string]interface{}{"in": []int{}}}, "m_a.id"},
+		{map[string]interface{}{"id": map[string]interface{}{"nin": 1}}, "m_a.id"},
+		{map[string]interface{}{"id": map[string]interface{}{"between": []int{1}}}, "m_a.id"},
+		{map[string]interface{}{"x": map[string]interface{}{"null": "yes"}}, "m_a.x"},
+		{map[string]interface{}{"x": map[string]interface{}{"regexp": "a"}}, "m_a.x"},
+		{map[string]interface{}{"x": map[string]interface{}{}}, "m_a.x"},
+		{map[string]interface{}{"$or": "x"}, "$or"},
+		{map[string]interface{}{"$or": []interface{}{"x"}}, "$or"},
+		{map[string]interface{}{"z": 1}, "z"},
+		{map[string]interface{}{"x; DROP TABLE m_a": 1}, "x; DROP TABLE m_a"},
+		{map[string]interface{}{"(SELECT 1).x": 1}, "(SELECT 1).x"},
+		{map[string]interface{}{"$or": []interface{}{map[string]interface{}{"x_gsql": "1=1"}}}, "x_gsql"},
+		{map[string]interface{}{"$or": []interface{}{map[string]interface{}{"$or": []map[string]interface{}{{"x": 1}, {"y_gsql": "1=1"}}}}}, "y_gsql"},
+	} {
+		_, _, err := selectCondition(c.extra, "m_a", MySQL, cols)
+		var e *Error
+		if !errors.Is(err, ErrValidation) || !errors.As(err, &e) || e.Column != c.column {
+			t.Errorf("%v: %v", c.extra, err)
+		}
+	}
+
+	// any column is accepted if the table has none
+	if where, _, err := selectCondition(map[string]interface{}{"z": 1}, "", MySQL, nil); err != nil || where != "(z =?)" {
+		t.Errorf("%s %v", where, err)
+	}
+}
@@ -2,8 +2,8 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 )
@@ -15,6 +15,9 @@ type Graph struct {
 	argsMap map[string]interface{}
 	extraMap map[string]interface{}
 	questionNumber DBType
+	concurrency chan struct{}
+	maxDepth int
+	maxRows int
 }
 
 func NewGraphJsonFile(fn string, cmap ...map[string][]Capability) (*Graph, error) {
@@ -51,8 +54,40 @@ func NewGraphJson(dat json.RawMessage, cmap ...map[string][]Capability) (*Graph,
 	return &Graph{Models:models}, nil
 }
 
+// NewGraphJsonStrict parses the graph as NewGraphJson, and then checks
+// it by Validate and CheckCycles, so that a misconfigured graph, or one
+// with a loop of prepares and nextpages, fails at load time.
+//
+func NewGraphJsonStrict(dat json.RawMessage, cmap ...map[string][]Capability) (*Graph, error) {
+	graph, err := NewGraphJson(dat, cmap...)
+	if err != nil {
+		return nil, err
+	}
+	if err = graph.Validate(); err != nil {
+		return nil, err
+	}
+	if err = graph.CheckCycles(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// NewGraphJsonFileStrict parses the graph file as NewGraphJsonFile, and
+// then checks it as NewGraphJsonStrict.
+//
+func NewGraphJsonFileStrict(fn string, cmap ...map[string][]Capability) (*Graph, error) {
+	dat, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphJsonStrict(json.RawMessage(dat), cmap...)
+}
+
 func (self *Graph) SetQuestionNumber(is DBType) {
     self.questionNumber = is
+	for _, item := range self.Models {
+		item.GetTable().SetQuestionNumber(is)
+	}
 }
 
 func (self *Graph) Initialize(args map[string]interface{}, extra map[string]interface{}) {
@@ -65,7 +100,9 @@ func (self *Graph) GetModel(model string) Navigate {
 		for _, item := range self.Models {
 			tableObj := item.GetTable()
 			if tableObj.GetTableName() == model {
-				tableObj.SetQuestionNumber(self.questionNumber)
+				if tableObj.questionNumber != self.questionNumber {
+					tableObj.SetQuestionNumber(self.questionNumber)
+				}
 				return item
 			}
 		}
@@ -73,6 +110,36 @@ func (self *Graph) GetModel(model string) Navigate {
 	return nil
 }
 
+// RunTxContext is the same as RunContext, but runs the action, and all its
+// prepares and nextpages, in one transaction. The transaction is committed
+// only if the whole tree succeeds, otherwise it is rolled back.
+//
+// If 'db' is already a *sql.Tx, the run joins it and the caller owns
+// the commit or rollback.
+//
+func (self *Graph) RunTxContext(ctx context.Context, db Executor, model, action string, rest ...interface{}) ([]map[string]interface{}, error) {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return self.RunContext(ctx, db, model, action, rest...)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := self.RunContext(ctx, tx, model, action, rest...)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("error original: %w, rollback: %v", err, rollbackErr)
+		}
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
 // RunContext runs action by model and action string names.
 // It returns the searched data and optional error code.
 //
@@ -80,7 +147,7 @@ func (self *Graph) GetModel(model string) Navigate {
 // The first extra is the input data, shared by all sub actions.
 // The rest are specific data for each action starting with the current one.
 //
-func (self *Graph) RunContext(ctx context.Context, db *sql.DB, model, action string, rest ...interface{}) ([]map[string]interface{}, error) {
+func (self *Graph) RunContext(ctx context.Context, db Executor, model, action string, rest ...interface{}) ([]map[string]interface{}, error) {
 	var args interface{}
 	var extra map[string]interface{}
 	if rest != nil {
@@ -111,6 +178,9 @@ func (self *Graph) RunContext(ctx context.Context, db *sql.DB, model, action str
 	case map[string]interface{}:
 		return self.hashContext(ctx, db, model, action, t, extra)
 	case []map[string]interface{}:
+		if self.isBulk(model, action) {
+			return self.bulkContext(ctx, db, model, action, t, extra)
+		}
 		var final []map[string]interface{}
 		for _, arg := range t {
 			lists, err := self.hashContext(ctx, db, model, action, arg, extra)
@@ -119,6 +189,15 @@ func (self *Graph) RunContext(ctx context.Context, db *sql.DB, model, action str
 		}
 		return final, nil
 	case []interface{}:
+		if self.isBulk(model, action) {
+			var rows []map[string]interface{}
+			for _, arg := range t {
+				if v, ok := arg.(map[string]interface{}); ok {
+					rows = append(rows, v)
+				}
+			}
+			return self.bulkContext(ctx, db, model, action, rows, extra)
+		}
 		var final []map[string]interface{}
 		for _, arg := range t {
 			if v, ok := arg.(map[string]interface{}); ok {
@@ -141,18 +220,22 @@ func (self *Graph) RunContext(ctx context.Context, db *sql.DB, model, action str
 // The first extra is the input data, shared by all sub actions.
 // The rest are specific data for each action starting with the current one.
 //
-func (self *Graph) hashContext(ctx context.Context, db *sql.DB, model, action string, args, extra map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Graph) hashContext(ctx context.Context, db Executor, model, action string, args, extra map[string]interface{}) ([]map[string]interface{}, error) {
 //fmt.Printf("\n\n1111 %s=>%s\nargs: %#v\n", model, action, args)
 	modelObj := self.GetModel(model)
 	if modelObj == nil {
-		return nil, fmt.Errorf("model %s not found in graph", model)
+		return nil, wrapError(newError(ErrConfig, "", "model %s not found in graph", model), model, action)
 	}
 
 	actionObj := modelObj.GetAction(action)
 	if actionObj == nil {
-		return nil, fmt.Errorf("action %s not found in graph", action)
+		return nil, wrapError(newError(ErrConfig, "", "action %s not found in graph", action), model, action)
 	}
 
+	ctx, err := self.enter(ctx, model, action)
+	if err != nil { return nil, err }
+	ctx, step := planStep(ctx, model, action, actionObj)
+
 	if args != nil && actionObj.GetIsDo() {
 		args = modelObj.GetTable().RefreshArgs(args).(map[string]interface{})
 	}
@@ -160,76 +243,276 @@ func (self *Graph) hashContext(ctx context.Context, db *sql.DB, model, action st
 	prepares := actionObj.GetPrepares()
 	nextpages := actionObj.GetNextpages()
 
-	newArgs := CloneArgs(args)
-	newExtra := CloneExtra(extra)
-	// prepares receives filtered args and extra from current args
-	if prepares != nil {
-		for _, p := range prepares {
-			// in case of prepare, we use args to get
-			// NextArgs and NextExtra as nextpage's input and constrains
-			preArgs := CloneArgs(args)
-			preExtra := CloneExtra(extra)
-			if p.TableName != model {
-				v, ok := p.FindArgs(preArgs)
-				pAction := self.GetModel(p.TableName).GetAction(p.ActionName)
-				if pAction.GetIsDo() && ok && !hasValue(v) {
-					continue
-				}
-				preArgs = MergeArgs(p.NextArgs(preArgs), v)
-				preExtra = MergeExtra(p.NextExtra(preArgs), p.FindExtra(preExtra))
-			}
-//fmt.Printf("22222 %d %s=>%s\n%#v\n", k, p.TableName, p.ActionName, preArgs)
-			lists, err := self.RunContext(ctx, db, p.TableName, p.ActionName, preArgs, preExtra)
-			if err != nil { return nil, err }
-			// only two types of prepares
-			// 1) one pre, with multiple outputs (when p.argsMap is multiple)
-			if hasValue(lists) && len(lists) > 1 {
-				var tmp []map[string]interface{}
-				newExtra = CloneExtra(extra)
-				for _, item := range lists {
-					result := MergeArgs(args, p.NextArgs(item)).(map[string]interface{})
-					tmp = append(tmp, result)
-					newExtra = MergeExtra(newExtra, p.NextExtra(item))
-				}
-				newArgs = tmp
-				break
-			}
-			// 2) multiple pre, with one output each.
-			// when a multiple output is found, 1) will override
-			if hasValue(lists) && hasValue(lists[0]) {
-				newArgs = MergeArgs(newArgs, p.NextArgs(lists[0]).(map[string]interface{}))
-				newExtra = MergeExtra(newExtra, p.NextExtra(lists[0]))
-			}
-		}
+	newArgs, newExtra, err := self.preparesContext(ctx, db, model, prepares, args, extra)
+	if err != nil { return nil, err }
+	if step != nil {
+		step.Args, step.Extra = newArgs, newExtra
 	}
 
 //fmt.Printf("33333 %s=>%s\n%#v\n", model, action, newArgs)
 	data, err := modelObj.RunModelContext(ctx, db, action, newArgs, newExtra)
+	if planPartial(ctx, step, err) { return nil, nil }
 	if err != nil { return nil, err }
+	if err = self.count(ctx, model, action, len(data)); err != nil { return nil, err }
+	keysetCursors(actionObj, newArgs, args)
 
 	if nextpages == nil {
 		return data, nil
 	}
 
-	for _, p := range nextpages {
-		for _, item := range data {
-			v, ok := p.FindArgs(newArgs)
-			pAction := self.GetModel(p.TableName).GetAction(p.ActionName)
-			// is a do-action, needs input from the table, but not found
+	return data, self.nextpagesContext(ctx, db, nextpages, newArgs, newExtra, data)
+}
+
+// keysetCursors copies the cursors of the next and previous pages of a
+// keyset Topics from the cloned args of the run to the caller's args.
+//
+func keysetCursors(actionObj Capability, newArgs interface{}, args map[string]interface{}) {
+	topics, ok := actionObj.(*Topics)
+	if !ok || !topics.Keyset || args == nil {
+		return
+	}
+	runArgs, ok := newArgs.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, name := range []string{topics.NEXTCURSOR, topics.PREVCURSOR} {
+		if v, ok := runArgs[name]; ok {
+			args[name] = v
+		} else {
+			delete(args, name)
+		}
+	}
+}
+
+// connectedAction returns the action of connection p, or an error if
+// not found, see also Validate.
+//
+func (self *Graph) connectedAction(p *Connection) (Capability, error) {
+	modelObj := self.GetModel(p.TableName)
+	if modelObj == nil {
+		return nil, wrapError(newError(ErrConfig, "", "model %s not found in graph", p.TableName), p.TableName, p.ActionName)
+	}
+	actionObj := modelObj.GetAction(p.ActionName)
+	if actionObj == nil {
+		return nil, wrapError(newError(ErrConfig, "", "action %s not found in graph", p.ActionName), p.TableName, p.ActionName)
+	}
+	return actionObj, nil
+}
+
+// runConnection runs the action of connection p, a prepare or a nextpage.
+// A missing row, from edit, update or delete, only means that there are no
+// related rows, so it is not an error.
+//
+func (self *Graph) runConnection(ctx context.Context, db Executor, p *Connection, prepare bool, args interface{}, extra map[string]interface{}) ([]map[string]interface{}, error) {
+	lists, err := self.RunContext(planLink(ctx, p, prepare), db, p.TableName, p.ActionName, args, extra)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return lists, err
+}
+
+// preparesContext runs prepares on args and extra of the current action,
+// and returns the new args and extra, merged with the outputs of prepares.
+// With SetConcurrency, read-only prepares run at the same time, and are
+// merged in their order.
+//
+func (self *Graph) preparesContext(ctx context.Context, db Executor, model string, prepares []*Connection, args, extra map[string]interface{}) (interface{}, map[string]interface{}, error) {
+	newArgs := CloneArgs(args)
+	newExtra := CloneExtra(extra)
+	if prepares == nil {
+		return newArgs, newExtra, nil
+	}
+
+	// prepares receives filtered args and extra from current args
+	var ps []*Connection
+	var actions []Capability
+	var preArgsList []interface{}
+	var preExtraList []map[string]interface{}
+	for _, p := range prepares {
+		// in case of prepare, we use args to get
+		// NextArgs and NextExtra as nextpage's input and constrains
+		preArgs := CloneArgs(args)
+		preExtra := CloneExtra(extra)
+		pAction, err := self.connectedAction(p)
+		if err != nil { return nil, nil, err }
+		if p.TableName != model {
+			v, ok := p.FindArgs(preArgs)
 			if pAction.GetIsDo() && ok && !hasValue(v) {
 				continue
 			}
-			nextArgs  := MergeArgs(p.NextArgs(item), v)
-			nextExtra := MergeExtra(p.NextExtra(item), p.FindExtra(newExtra))
+			preArgs = MergeArgs(p.NextArgs(preArgs), v)
+			preExtra = MergeExtra(p.NextExtra(preArgs), p.FindExtra(preExtra))
+		}
+		ps = append(ps, p)
+		actions = append(actions, pAction)
+		preArgsList = append(preArgsList, preArgs)
+		preExtraList = append(preExtraList, preExtra)
+	}
+
+	var results [][]map[string]interface{}
+	if self.concurrent(db, actions) {
+		results = make([][]map[string]interface{}, len(ps))
+		err := self.runTasks(ctx, len(ps), func(ctx context.Context, i int) error {
+			var err error
+			results[i], err = self.runConnection(ctx, db, ps[i], true, preArgsList[i], preExtraList[i])
+			return err
+		})
+		if err != nil { return nil, nil, err }
+	}
+
+	for i, p := range ps {
+		var lists []map[string]interface{}
+		if results != nil {
+			lists = results[i]
+		} else {
+//fmt.Printf("22222 %d %s=>%s\n%#v\n", i, p.TableName, p.ActionName, preArgsList[i])
+			var err error
+			lists, err = self.runConnection(ctx, db, p, true, preArgsList[i], preExtraList[i])
+			if err != nil { return nil, nil, err }
+		}
+		// only two types of prepares
+		// 1) one pre, with multiple outputs (when p.argsMap is multiple)
+		if hasValue(lists) && len(lists) > 1 {
+			var tmp []map[string]interface{}
+			newExtra = CloneExtra(extra)
+			for _, item := range lists {
+				result := MergeArgs(args, p.NextArgs(item)).(map[string]interface{})
+				tmp = append(tmp, result)
+				newExtra = MergeExtra(newExtra, p.NextExtra(item))
+			}
+			newArgs = tmp
+			break
+		}
+		// 2) multiple pre, with one output each.
+		// when a multiple output is found, 1) will override
+		if hasValue(lists) && hasValue(lists[0]) {
+			newArgs = MergeArgs(newArgs, p.NextArgs(lists[0]).(map[string]interface{}))
+			newExtra = MergeExtra(newExtra, p.NextExtra(lists[0]))
+		}
+	}
+
+	return newArgs, newExtra, nil
+}
+
+// nextpagesContext runs nextpages on each item of data, which is the output
+// of the current action on newArgs and newExtra, and saves their outputs into
+// the item under Subname. A nextpage related by RelateExtra only runs once
+// for all items, see batchNextpage. With SetConcurrency, read-only nextpages
+// run at the same time, and are saved in their order.
+//
+func (self *Graph) nextpagesContext(ctx context.Context, db Executor, nextpages []*Connection, newArgs interface{}, newExtra map[string]interface{}, data []map[string]interface{}) error {
+	var ps []*Connection
+	var actions []Capability
+	var vs []interface{}
+	for _, p := range nextpages {
+		v, ok := p.FindArgs(newArgs)
+		pAction, err := self.connectedAction(p)
+		if err != nil { return err }
+		// is a do-action, needs input from the table, but not found
+		if pAction.GetIsDo() && ok && !hasValue(v) {
+			continue
+		}
+		ps = append(ps, p)
+		actions = append(actions, pAction)
+		vs = append(vs, v)
+	}
+
+	if !self.concurrent(db, actions) {
+		for i, p := range ps {
+			outputs, err := self.nextpageContext(ctx, db, p, actions[i], vs[i], newExtra, data)
+			if err != nil { return err }
+			p.save(data, outputs)
+		}
+		return nil
+	}
+
+	results := make([][]interface{}, len(ps))
+	err := self.runTasks(ctx, len(ps), func(ctx context.Context, i int) error {
+		var err error
+		results[i], err = self.nextpageContext(ctx, db, ps[i], actions[i], vs[i], newExtra, data)
+		return err
+	})
+	if err != nil { return err }
+	for i, p := range ps {
+		p.save(data, results[i])
+	}
+	return nil
+}
+
+// nextpageContext runs nextpage p on each item of data, and returns their
+// shortened outputs, nil for those of no output.
+//
+func (self *Graph) nextpageContext(ctx context.Context, db Executor, p *Connection, pAction Capability, v interface{}, newExtra map[string]interface{}, data []map[string]interface{}) ([]interface{}, error) {
+	outputs, batched, err := self.batchNextpage(ctx, db, p, pAction, v, newExtra, data)
+	if batched {
+		return outputs, err
+	}
+
+	outputs = make([]interface{}, len(data))
+	for i, item := range data {
+		nextArgs  := MergeArgs(p.NextArgs(item), v)
+		nextExtra := MergeExtra(p.NextExtra(item), p.FindExtra(newExtra))
 //fmt.Printf("9999 %v\nnext args: %#v\nnext extra: %#v\n", p, nextArgs, nextExtra)
-			newLists, err := self.RunContext(ctx, db, p.TableName, p.ActionName, nextArgs, nextExtra)
-			if err != nil { return nil, err }
-			if hasValue(newLists) {
+		newLists, err := self.runConnection(ctx, db, p, false, nextArgs, nextExtra)
+		if err != nil { return nil, err }
+		if hasValue(newLists) {
 //fmt.Printf("10000 %#v:%d\n%#v\n\n", p, len(newLists), newLists)
-				item[p.Subname()] =  p.Shorten(newLists)
-			}
+			outputs[i] = p.Shorten(newLists)
 		}
 	}
+	return outputs, nil
+}
+
+// bulkContext runs a bulk action, such as bulkinsert, on all rows of args
+// at once, then the nextpages on each output row. Prepares are not run.
+//
+func (self *Graph) bulkContext(ctx context.Context, db Executor, model, action string, args []map[string]interface{}, extra map[string]interface{}) ([]map[string]interface{}, error) {
+	modelObj := self.GetModel(model)
+	actionObj := modelObj.GetAction(action)
+	ctx, err := self.enter(ctx, model, action)
+	if err != nil { return nil, err }
+	ctx, step := planStep(ctx, model, action, actionObj)
+
+	var newArgs []map[string]interface{}
+	for _, arg := range args {
+		if actionObj.GetIsDo() {
+			arg = modelObj.GetTable().RefreshArgs(arg).(map[string]interface{})
+		}
+		newArgs = append(newArgs, CloneArgs(arg).(map[string]interface{}))
+	}
+	newExtra := CloneExtra(extra)
+	if step != nil {
+		step.Args, step.Extra = newArgs, newExtra
+	}
 
+	data, err := modelObj.RunModelContext(ctx, db, action, newArgs, newExtra)
+	if planPartial(ctx, step, err) { return nil, nil }
+	if err != nil { return nil, err }
+	if err = self.count(ctx, model, action, len(data)); err != nil { return nil, err }
+
+	nextpages := actionObj.GetNextpages()
+	if nextpages == nil {
+		return data, nil
+	}
+	for i, item := range data {
+		var arg interface{}
+		if i < len(newArgs) {
+			arg = newArgs[i]
+		}
+		if err := self.nextpagesContext(ctx, db, nextpages, arg, newExtra, []map[string]interface{}{item}); err != nil {
+			return nil, err
+		}
+	}
 	return data, nil
 }
+
+// isBulk tells if the action of model runs all rows of a slice at once.
+//
+func (self *Graph) isBulk(model, action string) bool {
+	modelObj := self.GetModel(model)
+	if modelObj == nil {
+		return false
+	}
+	_, ok := modelObj.GetAction(action).(bulkRunner)
+	return ok
+}
@@ -30,7 +30,8 @@ func TestGraphDelecs(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db, ctx, METHODS := local2Vars()
+	db, ctx, METHODS := local2Vars(t)
+	graph.SetQuestionNumber(testDBType)
 	var lists []map[string]interface{}
 
 	// the 1st web requests is assumed to create id=1 to the m_a and m_b tables:
@@ -93,7 +94,8 @@ func TestGraphDelecs2(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db, ctx, METHODS := local2Vars()
+	db, ctx, METHODS := local2Vars(t)
+	graph.SetQuestionNumber(testDBType)
 	var lists []map[string]interface{}
 
 	// the 1st web requests is assumed to create id=1 to the m_a and m_b tables:
@@ -158,3 +160,44 @@ func TestGraphThreeTables2(t *testing.T) {
 	}
 	GraphThreeGeneral(graph, t)
 }
+
+func TestGraphTx(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, METHODS := local2Vars(t)
+	graph.SetQuestionNumber(testDBType)
+	defer db.Close()
+
+	// the nextpage insert into m_b fails, so insupd on m_a is rolled back
+	db.Exec(`drop table if exists m_b`)
+	args := map[string]interface{}{"x": "a1234567", "y": "b1234567", "z": "temp"}
+	graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"insert": map[string]interface{}{"child": "john"}}}, nil)
+	if _, err = graph.RunTxContext(ctx, db, "m_a", METHODS["PATCH"], args); err == nil {
+		t.Errorf("nextpage error expected")
+	}
+	lists, err := graph.RunContext(ctx, db, "m_a", "topics", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 0 {
+		t.Errorf("%v", lists)
+	}
+
+	// the whole tree succeeds and is committed
+	db.Exec(portable(`CREATE TABLE m_b (tid int auto_increment not null primary key, child varchar(8), id int)`))
+	if _, err = graph.RunTxContext(ctx, db, "m_a", METHODS["PATCH"], args); err != nil {
+		t.Fatal(err)
+	}
+	lists, err = graph.RunContext(ctx, db, "m_b", "topics", map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["child"] != "john" {
+		t.Errorf("%v", lists)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
@@ -6,15 +6,15 @@ import (
 	"testing"
 )
 
-func local2Vars() (*sql.DB, context.Context, map[string]string) {
+func local2Vars(t *testing.T) (*sql.DB, context.Context, map[string]string) {
 	db, err := getdb()
 	if err != nil {
-		panic(err)
+		skipNoDB(t, err)
 	}
 	db.Exec(`drop table if exists m_b`)
 	db.Exec(`drop table if exists m_a`)
-	db.Exec(`CREATE TABLE m_a (id int auto_increment not null primary key, x varchar(8), y varchar(8), z varchar(8))`)
-	db.Exec(`CREATE TABLE m_b (tid int auto_increment not null primary key, child varchar(8), id int)`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key, x varchar(8), y varchar(8), z varchar(8), UNIQUE (x, y))`))
+	db.Exec(portable(`CREATE TABLE m_b (tid int auto_increment not null primary key, child varchar(8), id int)`))
 	return db, context.Background(), map[string]string{"LIST": "topics", "GET": "edit", "POST": "insert", "PUT": "update", "PATCH": "insupd", "DELETE": "delete"}
 }
 
@@ -87,7 +87,8 @@ func graph2Check(ctx context.Context, db *sql.DB, graph *Graph, METHODS map[stri
 }
 
 func GraphGeneral(t *testing.T, graph *Graph) {
-	db, ctx, METHODS := local2Vars()
+	db, ctx, METHODS := local2Vars(t)
+	graph.SetQuestionNumber(testDBType)
 	var lists []map[string]interface{}
 
 	// the 1st web requests is assumed to create id=1 to the m_a and m_b tables:
@@ -209,15 +210,16 @@ func GraphGeneral(t *testing.T, graph *Graph) {
 func GraphThreeGeneral(graph *Graph, t *testing.T) {
     db, err := getdb()
     if err != nil {
-        panic(err)
+        skipNoDB(t, err)
     }
 	db.Exec(`drop table if exists m_b`)
 	db.Exec(`drop table if exists m_ab`)
 	db.Exec(`drop table if exists m_a`)
-	db.Exec(`CREATE TABLE m_a (id int auto_increment not null primary key, x varchar(8), y varchar(8), z varchar(8))`)
-	db.Exec(`CREATE TABLE m_ab (abid int auto_increment not null primary key, id int, tid int)`)
-	db.Exec(`CREATE TABLE m_b (tid int auto_increment not null primary key, child varchar(8))`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key, x varchar(8), y varchar(8), z varchar(8), UNIQUE (x, y))`))
+	db.Exec(portable(`CREATE TABLE m_ab (abid int auto_increment not null primary key, id int, tid int, UNIQUE (id, tid))`))
+	db.Exec(portable(`CREATE TABLE m_b (tid int auto_increment not null primary key, child varchar(8), UNIQUE (child))`))
     ctx := context.Background()
+	graph.SetQuestionNumber(testDBType)
 	METHODS := map[string]string{"LIST": "topics", "GET": "edit", "POST": "insert", "PUT": "update", "PATCH": "insupd", "DELETE": "delete"}
 
 	var lists []map[string]interface{}
@@ -0,0 +1,151 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BeforeHook runs before an action, on the db of the action, which is the
+// transaction if there is one. It returns ARGS and extra, possibly changed,
+// for the action, or an error to abort it.
+//
+type BeforeHook func(ctx context.Context, db Executor, t *Table, action string, ARGS, extra map[string]interface{}) (map[string]interface{}, map[string]interface{}, error)
+
+// AfterHook runs after an action, on the db of the action, which is the
+// transaction if there is one. It returns the output rows, possibly
+// changed, or an error to fail the action.
+//
+type AfterHook func(ctx context.Context, db Executor, t *Table, action string, lists []map[string]interface{}) ([]map[string]interface{}, error)
+
+// hookRegistry keeps the hooks registered by name.
+//
+var hookRegistry sync.Map
+
+type hookKey struct {
+	after bool
+	name  string
+}
+
+// RegisterBeforeHook registers a before hook by name, so it can be
+// referenced in the 'before' list of an action in JSON.
+//
+func RegisterBeforeHook(name string, hook BeforeHook) {
+	hookRegistry.Store(hookKey{false, name}, hook)
+}
+
+// RegisterAfterHook registers an after hook by name, so it can be
+// referenced in the 'after' list of an action in JSON.
+//
+func RegisterAfterHook(name string, hook AfterHook) {
+	hookRegistry.Store(hookKey{true, name}, hook)
+}
+
+// hooker is an action with hooks, implemented by Action.
+//
+type hooker interface {
+	getHooks() ([]BeforeHook, []AfterHook, error)
+}
+
+// AddBefore adds a before hook to the action, which runs after those named in Before.
+//
+func (self *Action) AddBefore(hook BeforeHook) {
+	self.befores = append(self.befores, hook)
+}
+
+// AddAfter adds an after hook to the action, which runs after those named in After.
+//
+func (self *Action) AddAfter(hook AfterHook) {
+	self.afters = append(self.afters, hook)
+}
+
+// getHooks returns the hooks named in Before and After, then those added in Go.
+//
+func (self *Action) getHooks() ([]BeforeHook, []AfterHook, error) {
+	var befores []BeforeHook
+	for _, name := range self.Before {
+		hook, ok := hookRegistry.Load(hookKey{false, name})
+		if !ok {
+			return nil, nil, fmt.Errorf("before hook %s not registered", name)
+		}
+		befores = append(befores, hook.(BeforeHook))
+	}
+	var afters []AfterHook
+	for _, name := range self.After {
+		hook, ok := hookRegistry.Load(hookKey{true, name})
+		if !ok {
+			return nil, nil, fmt.Errorf("after hook %s not registered", name)
+		}
+		afters = append(afters, hook.(AfterHook))
+	}
+	return append(befores, self.befores...), append(afters, self.afters...), nil
+}
+
+// AddBefore adds a before hook to action of the model.
+//
+func (self *Model) AddBefore(action string, hook BeforeHook) error {
+	obj, ok := self.GetAction(action).(interface{ AddBefore(BeforeHook) })
+	if !ok {
+		return newError(ErrConfig, "", "action %s not found in model %s", action, self.TableName)
+	}
+	obj.AddBefore(hook)
+	return nil
+}
+
+// AddAfter adds an after hook to action of the model.
+//
+func (self *Model) AddAfter(action string, hook AfterHook) error {
+	obj, ok := self.GetAction(action).(interface{ AddAfter(AfterHook) })
+	if !ok {
+		return newError(ErrConfig, "", "action %s not found in model %s", action, self.TableName)
+	}
+	obj.AddAfter(hook)
+	return nil
+}
+
+// actionHooks returns the hooks of obj, if it has any. No hook runs in a
+// dry run, as a hook may write outside of db.
+//
+func actionHooks(ctx context.Context, obj Capability) ([]BeforeHook, []AfterHook, error) {
+	if _, ok := ctx.Value(planStateKey{}).(*planState); ok {
+		return nil, nil, nil
+	}
+	if h, ok := obj.(hooker); ok {
+		return h.getHooks()
+	}
+	return nil, nil, nil
+}
+
+// runBefores runs the before hooks on ARGS and extra.
+//
+func runBefores(ctx context.Context, db Executor, t *Table, action string, befores []BeforeHook, ARGS map[string]interface{}, extra ...map[string]interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+	if befores == nil {
+		return ARGS, extra, nil
+	}
+	var ex map[string]interface{}
+	if hasValue(extra) {
+		ex = extra[0]
+	}
+	var err error
+	for _, hook := range befores {
+		if ARGS, ex, err = hook(ctx, db, t, action, ARGS, ex); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ex == nil {
+		return ARGS, nil, nil
+	}
+	return ARGS, []map[string]interface{}{ex}, nil
+}
+
+// runAfters runs the after hooks on the output rows.
+//
+func runAfters(ctx context.Context, db Executor, t *Table, action string, afters []AfterHook, lists []map[string]interface{}) ([]map[string]interface{}, error) {
+	var err error
+	for _, hook := range afters {
+		if lists, err = hook(ctx, db, t, action, lists); err != nil {
+			return nil, err
+		}
+	}
+	return lists, nil
+}
@@ -0,0 +1,111 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists m_h`)
+	db.Exec(`drop table if exists m_h_log`)
+	db.Exec(portable(`CREATE TABLE m_h (id int auto_increment not null primary key, x varchar(8))`))
+	db.Exec(`CREATE TABLE m_h_log (x varchar(8))`)
+
+	RegisterBeforeHook("lower_x", func(ctx context.Context, db Executor, t *Table, action string, ARGS, extra map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+		if x, ok := ARGS["x"].(string); ok {
+			ARGS["x"] = strings.ToLower(x)
+		}
+		return ARGS, extra, nil
+	})
+	RegisterAfterHook("log_x", func(ctx context.Context, db Executor, t *Table, action string, lists []map[string]interface{}) ([]map[string]interface{}, error) {
+		for _, item := range lists {
+			if _, err := db.ExecContext(ctx, `INSERT INTO m_h_log (x) VALUES (?)`, item["x"]); err != nil {
+				return nil, err
+			}
+		}
+		return lists, nil
+	})
+	RegisterAfterHook("mark", func(ctx context.Context, db Executor, t *Table, action string, lists []map[string]interface{}) ([]map[string]interface{}, error) {
+		for _, item := range lists {
+			item["hooked"] = t.TableName + " " + action
+		}
+		return lists, nil
+	})
+
+	graph, err := NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_h", "pks":["id"], "idAuto":"id",
+"columns":[{"columnName":"x", "label":"x", "typeName":"string", "notnull":true}, {"columnName":"id", "label":"id", "typeName":"int", "auto":true}],
+"actions":[{"actionName":"insert", "before":["lower_x"], "after":["log_x"]}, {"actionName":"topics", "after":["mark"]}, {"actionName":"delete", "before":["missing"]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetQuestionNumber(testDBType)
+	model := graph.GetModel("m_h").(*Model)
+
+	lists, err := graph.RunContext(ctx, db, "m_h", "insert", map[string]interface{}{"x": "ABC"})
+	if err != nil || lists[0]["x"] != "abc" {
+		t.Fatalf("%v %v", lists, err)
+	}
+
+	// a hook added in Go runs after the named ones, and aborts the action
+	err = model.AddBefore("insert", func(ctx context.Context, db Executor, t *Table, action string, ARGS, extra map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+		if ARGS["x"] == "bad" {
+			return nil, nil, errors.New("bad x")
+		}
+		return ARGS, extra, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = graph.RunContext(ctx, db, "m_h", "insert", map[string]interface{}{"x": "BAD"})
+	var e *Error
+	if !errors.As(err, &e) || e.Model != "m_h" || e.Action != "insert" || err.Error() != "m_h insert: bad x" {
+		t.Errorf("%#v", err)
+	}
+
+	// the after hook runs in the transaction, and is rolled back with it
+	_, err = graph.RunTxContext(ctx, db, "m_h", "insert", []map[string]interface{}{{"x": "good"}, {"x": "bad"}})
+	if err == nil {
+		t.Errorf("bad x expected to fail")
+	}
+	logs := make([]map[string]interface{}, 0)
+	dbi := &DBI{DB: db}
+	if err = dbi.SelectContext(ctx, &logs, `SELECT x FROM m_h_log`); err != nil || len(logs) != 1 || logs[0]["x"] != "abc" {
+		t.Errorf("%v %v", logs, err)
+	}
+
+	lists, err = model.RunModelContext(ctx, db, "topics", nil)
+	if err != nil || len(lists) != 1 || lists[0]["hooked"] != "m_h topics" {
+		t.Errorf("%v %v", lists, err)
+	}
+	n := 0
+	err = model.StreamModelContext(ctx, db, func(item map[string]interface{}) error {
+		if item["hooked"] != "m_h topics" {
+			t.Errorf("%v", item)
+		}
+		n++
+		return nil
+	}, "topics", nil)
+	if err != nil || n != 1 {
+		t.Errorf("%d %v", n, err)
+	}
+
+	if _, err = model.RunModelContext(ctx, db, "delete", map[string]interface{}{"id": 1}); err == nil || !strings.Contains(err.Error(), "before hook missing not registered") {
+		t.Errorf("%v", err)
+	}
+	if err = model.AddAfter("wrong", nil); !errors.Is(err, ErrConfig) {
+		t.Errorf("%v", err)
+	}
+
+	db.Exec(`drop table if exists m_h`)
+	db.Exec(`drop table if exists m_h_log`)
+}
@@ -2,14 +2,99 @@ package godbi
 
 import (
 	"database/sql"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
+	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
+// testDBType is the database of the tests: MySQL if DBUSER is set,
+// otherwise a SQLite file in the temporary directory.
+//
+var testDBType = SQLite
+
+// sqliteDriver is the name of the SQLite driver of the tests: "sqlite" of
+// the pure-Go modernc.org/sqlite by default, or that in GODBI_SQLITE_DRIVER,
+// such as "sqlite3" of mattn/go-sqlite3, registered in sqlite_test.go when
+// built with cgo.
+//
+var sqliteDriver = os.Getenv("GODBI_SQLITE_DRIVER")
+
+func init() {
+	if sqliteDriver == "" {
+		sqliteDriver = "sqlite"
+	}
+}
+
+var errNoSQLite = errors.New("SQLite driver in GODBI_SQLITE_DRIVER not linked: build with cgo for sqlite3")
+
+// sqliteDSN returns the data source of the SQLite file, which waits 5
+// seconds for a lock, in the parameter of the driver.
+//
+func sqliteDSN(file string) string {
+	if sqliteDriver == "sqlite" {
+		return file + "?_pragma=busy_timeout(5000)"
+	}
+	return file + "?_busy_timeout=5000"
+}
+
 func getdb() (*sql.DB, error) {
 	dbUser := os.Getenv("DBUSER")
+	if dbUser == "" {
+		testDBType = SQLite
+		if !grep(sql.Drivers(), sqliteDriver) {
+			return nil, errNoSQLite
+		}
+		return sql.Open(sqliteDriver, sqliteDSN(filepath.Join(os.TempDir(), "godbi_test.db")))
+	}
+	testDBType = MySQL
 	dbPass := os.Getenv("DBPASS")
 	dbName := os.Getenv("DBNAME")
 	return sql.Open("mysql", dbUser+":"+dbPass+"@/"+dbName)
 }
+
+// portable translates the MySQL auto increment column in a CREATE TABLE
+// statement of the tests into SQLite.
+//
+func portable(str string) string {
+	if testDBType != SQLite {
+		return str
+	}
+	return strings.Replace(str, "int auto_increment not null primary key", "integer primary key", -1)
+}
+
+// skipProcedures skips a test of stored procedures, which SQLite does not have.
+//
+func skipProcedures(t *testing.T) {
+	if testDBType == SQLite {
+		t.Skip("stored procedures not supported in SQLite")
+	}
+}
+
+// skipNoDB skips the test if the database of the tests is not available,
+// or fails it on other errors.
+//
+func skipNoDB(t *testing.T, err error) {
+	if errors.Is(err, errNoSQLite) {
+		t.Skip(err)
+	}
+	t.Fatal(err)
+}
+
+// opensqlite opens the SQLite database of dsn, or skips the test if
+// there is no SQLite driver.
+//
+func opensqlite(t *testing.T, dsn string) *sql.DB {
+	if !grep(sql.Drivers(), sqliteDriver) {
+		t.Skip(errNoSQLite)
+	}
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -2,8 +2,6 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 )
 
 type Insert struct {
@@ -13,18 +11,17 @@ type Insert struct {
 // Run inserts a row using data passed in ARGS. Any value defined
 // in 'extra' will override that key in ARGS.
 //
-func (self *Insert) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Insert) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
 // InsertContext inserts a row using data passed in ARGS. Any value defined
 // in 'extra' will override that key in ARGS.
 //
-func (self *Insert) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
-	if self.IsDo {
-		if err := t.checkNull(ARGS, extra...); err != nil {
-			return nil, err
-		}
+func (self *Insert) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	ARGS, err := t.validate(ARGS, self.IsDo, extra...)
+	if err != nil {
+		return nil, err
 	}
 
 	fieldValues := t.getFv(ARGS)
@@ -39,7 +36,7 @@ func (self *Insert) RunActionContext(ctx context.Context, db *sql.DB, t *Table,
 		}
 	}
 	if fieldValues == nil || len(fieldValues) == 0 {
-		return nil, fmt.Errorf("no data to insert")
+		return nil, newError(ErrValidation, "", "no data to insert")
 	}
 
 	autoID, err := t.insertHashContext(ctx, db, fieldValues)
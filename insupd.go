@@ -2,23 +2,24 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 )
 
 type Insupd struct {
 	Action
+	// SelectFirst: select the row, then update or insert it, instead of the
+	// native upsert of SQLite, Postgres and MySQL, for a table without a
+	// unique index on exactly its Uniques
+	SelectFirst bool `json:"selectFirst,omitempty" hcl:"selectFirst,optional"`
 }
 
-func (self *Insupd) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Insupd) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
-func (self *Insupd) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
-	if self.IsDo {
-		if err := t.checkNull(ARGS, extra...); err != nil {
-			return nil, err
-		}
+func (self *Insupd) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	ARGS, err := t.validate(ARGS, self.IsDo, extra...)
+	if err != nil {
+		return nil, err
 	}
 
 	fieldValues := t.getFv(ARGS)
@@ -33,10 +34,10 @@ func (self *Insupd) RunActionContext(ctx context.Context, db *sql.DB, t *Table,
 		}
 	}
 	if fieldValues == nil || len(fieldValues) == 0 {
-		return nil, fmt.Errorf("input not found")
+		return nil, newError(ErrValidation, "", "input not found")
 	}
 
-	changed, err := t.insupdTableContext(ctx, db, fieldValues)
+	changed, err := t.insupdTableContext(ctx, db, fieldValues, self.SelectFirst)
 	if err != nil {
 		return nil, err
 	}
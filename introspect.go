@@ -0,0 +1,476 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IntrospectContext reads the definitions of 'tables', or all tables if
+// none is given, from a live database. It uses information_schema for
+// MySQL and Postgres, and sqlite_master and PRAGMA for SQLite.
+//
+// The column types are translated into the label types used in SelectSQL,
+// such as "int", "int64", "float64", "bool" and "string", and the length
+// of a character column is kept in Max. Uniques takes the first unique
+// key, other than the primary key, in the order of its name.
+//
+func IntrospectContext(ctx context.Context, db Executor, dbType DBType, tables ...string) ([]*Table, error) {
+	dbi := &DBI{Executor: db, DBType: dbType}
+	var outs []*Table
+	var err error
+	switch dbType {
+	case SQLite:
+		outs, err = dbi.introspectSQLite(ctx)
+	case Postgres:
+		outs, err = dbi.introspectSchema(ctx, postgresSchema)
+	case MySQL, SQLDefault:
+		outs, err = dbi.introspectSchema(ctx, mysqlSchema)
+	default:
+		return nil, fmt.Errorf("introspection not supported for database type %d", dbType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tables == nil {
+		return outs, nil
+	}
+	var selected []*Table
+	for _, table := range outs {
+		if grep(tables, table.TableName) {
+			selected = append(selected, table)
+		}
+	}
+	for _, name := range tables {
+		found := false
+		for _, table := range selected {
+			if table.TableName == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("table %s not found in database", name)
+		}
+	}
+	return selected, nil
+}
+
+// schemaQueries are the information_schema queries of a dialect. Each query
+// returns the columns in the order of the labels used in introspectSchema.
+//
+type schemaQueries struct {
+	columns string
+	keys    string
+	fks     string
+	labelType func(dataType, columnType string) string
+}
+
+var mysqlSchema = &schemaQueries{
+	columns: `SELECT table_name, column_name, data_type, column_type, is_nullable, extra, character_maximum_length
+FROM information_schema.columns
+WHERE table_schema = DATABASE()
+ORDER BY table_name, ordinal_position`,
+	keys: `SELECT s.table_name, s.index_name, CASE WHEN s.index_name = 'PRIMARY' THEN 'PRIMARY KEY' ELSE 'UNIQUE' END, s.column_name
+FROM information_schema.statistics s
+WHERE s.table_schema = DATABASE() AND s.non_unique = 0
+ORDER BY s.table_name, s.index_name, s.seq_in_index`,
+	fks: `SELECT table_name, column_name, referenced_table_name, referenced_column_name
+FROM information_schema.key_column_usage
+WHERE table_schema = DATABASE() AND referenced_table_name IS NOT NULL
+ORDER BY table_name, constraint_name, ordinal_position`,
+	labelType: func(dataType, columnType string) string {
+		unsigned := strings.Contains(columnType, "unsigned")
+		switch dataType {
+		case "tinyint":
+			if strings.HasPrefix(columnType, "tinyint(1)") {
+				return "bool"
+			}
+			return "int"
+		case "smallint", "mediumint", "int", "integer":
+			if unsigned && (dataType == "int" || dataType == "integer") {
+				return "int64"
+			}
+			return "int"
+		case "bigint":
+			if unsigned {
+				return "uint64"
+			}
+			return "int64"
+		case "float", "double", "real":
+			return "float64"
+		case "decimal", "numeric":
+			return "decimal"
+		case "json":
+			return "json"
+		case "bool", "boolean":
+			return "bool"
+		case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+			return "[]byte"
+		case "date":
+			return "date"
+		case "timestamp":
+			// stored in UTC and read in the time zone of the session
+			return "timestamptz"
+		case "datetime":
+			return "time"
+		default:
+		}
+		return "string"
+	},
+}
+
+var postgresSchema = &schemaQueries{
+	columns: `SELECT table_name, column_name, data_type, udt_name, is_nullable, CASE WHEN is_identity = 'YES' OR column_default LIKE 'nextval(%' THEN 'auto_increment' ELSE '' END, character_maximum_length
+FROM information_schema.columns
+WHERE table_schema = current_schema()
+ORDER BY table_name, ordinal_position`,
+	keys: `SELECT t.relname, i.relname, CASE WHEN ix.indisprimary THEN 'PRIMARY KEY' WHEN EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid) THEN 'UNIQUE CONSTRAINT' ELSE 'UNIQUE' END, a.attname
+FROM pg_index ix
+INNER JOIN pg_class t ON (t.oid = ix.indrelid)
+INNER JOIN pg_class i ON (i.oid = ix.indexrelid)
+INNER JOIN pg_namespace n ON (n.oid = t.relnamespace)
+INNER JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+INNER JOIN pg_attribute a ON (a.attrelid = t.oid AND a.attnum = k.attnum)
+WHERE n.nspname = current_schema() AND ix.indisunique
+ORDER BY t.relname, i.relname, k.ord`,
+	fks: `SELECT kcu.table_name, kcu.column_name, ref.table_name, ref.column_name
+FROM information_schema.referential_constraints rc
+INNER JOIN information_schema.key_column_usage kcu
+ON (rc.constraint_name = kcu.constraint_name AND rc.constraint_schema = kcu.constraint_schema)
+INNER JOIN information_schema.key_column_usage ref
+ON (rc.unique_constraint_name = ref.constraint_name AND rc.unique_constraint_schema = ref.constraint_schema AND kcu.position_in_unique_constraint = ref.ordinal_position)
+WHERE rc.constraint_schema = current_schema()
+ORDER BY kcu.table_name, kcu.constraint_name, kcu.ordinal_position`,
+	labelType: func(dataType, columnType string) string {
+		switch dataType {
+		case "smallint", "integer":
+			return "int"
+		case "bigint":
+			return "int64"
+		case "real", "double precision":
+			return "float64"
+		case "numeric":
+			return "decimal"
+		case "boolean":
+			return "bool"
+		case "date":
+			return "date"
+		case "timestamp with time zone":
+			return "timestamptz"
+		case "timestamp without time zone":
+			return "time"
+		case "uuid":
+			return "uuid"
+		case "json", "jsonb":
+			return "json"
+		case "bytea":
+			return "[]byte"
+		case "array":
+			// the udt_name of an array is that of its element prefixed by _
+			switch columnType {
+			case "_int2", "_int4", "_int8":
+				return "[]int64"
+			case "_float4", "_float8", "_numeric":
+				return "[]float64"
+			case "_bool":
+				return "[]bool"
+			default:
+			}
+			return "[]string"
+		default:
+		}
+		return "string"
+	},
+}
+
+func (self *DBI) introspectSchema(ctx context.Context, queries *schemaQueries) ([]*Table, error) {
+	var tables []*Table
+	find := func(name string) *Table {
+		for _, table := range tables {
+			if table.TableName == name {
+				return table
+			}
+		}
+		return nil
+	}
+
+	lists := make([]map[string]interface{}, 0)
+	labels := []interface{}{[2]string{"table", "string"}, [2]string{"column", "string"}, [2]string{"dataType", "string"}, [2]string{"columnType", "string"}, [2]string{"nullable", "string"}, [2]string{"extra", "string"}, [2]string{"length", "int64"}}
+	if err := self.SelectSQLContext(ctx, &lists, queries.columns, labels); err != nil {
+		return nil, err
+	}
+	for _, item := range lists {
+		name := item["table"].(string)
+		table := find(name)
+		if table == nil {
+			table = &Table{TableName: name}
+			tables = append(tables, table)
+		}
+		column := item["column"].(string)
+		dataType := strings.ToLower(fmt.Sprint(item["dataType"]))
+		columnType := strings.ToLower(fmt.Sprint(item["columnType"]))
+		col := &Col{ColumnName: column, Label: column, TypeName: queries.labelType(dataType, columnType), Notnull: item["nullable"] == "NO"}
+		if length, ok := item["length"].(int64); ok && isCharType(dataType) {
+			col.setLength(length)
+		}
+		if strings.Contains(strings.ToLower(fmt.Sprint(item["extra"])), "auto_increment") {
+			col.Auto = true
+			table.IdAuto = column
+		}
+		table.Columns = append(table.Columns, col)
+	}
+
+	lists = make([]map[string]interface{}, 0)
+	labels = []interface{}{[2]string{"table", "string"}, [2]string{"name", "string"}, [2]string{"type", "string"}, [2]string{"column", "string"}}
+	if err := self.SelectSQLContext(ctx, &lists, queries.keys, labels); err != nil {
+		return nil, err
+	}
+	uniques := make(map[string]string)
+	for _, item := range lists {
+		table := find(item["table"].(string))
+		if table == nil {
+			continue
+		}
+		column := item["column"].(string)
+		if item["type"] == "PRIMARY KEY" {
+			table.Pks = append(table.Pks, column)
+			continue
+		}
+		name := item["name"].(string)
+		if first, ok := uniques[table.TableName]; ok && first != name {
+			continue
+		}
+		uniques[table.TableName] = name
+		table.Uniques = append(table.Uniques, column)
+		table.uniqueIndex = name
+		table.uniqueConstraint = item["type"] == "UNIQUE CONSTRAINT"
+	}
+
+	lists = make([]map[string]interface{}, 0)
+	labels = []interface{}{[2]string{"table", "string"}, [2]string{"column", "string"}, [2]string{"fkTable", "string"}, [2]string{"fkColumn", "string"}}
+	if err := self.SelectSQLContext(ctx, &lists, queries.fks, labels); err != nil {
+		return nil, err
+	}
+	for _, item := range lists {
+		if table := find(item["table"].(string)); table != nil {
+			table.Fks = append(table.Fks, &Fk{FkTable: item["fkTable"].(string), FkColumn: item["fkColumn"].(string), Column: item["column"].(string)})
+		}
+	}
+
+	for _, table := range tables {
+		table.SetQuestionNumber(self.DBType)
+	}
+	return tables, nil
+}
+
+// isCharType tells if dataType is a character type of a declared length.
+//
+func isCharType(dataType string) bool {
+	switch dataType {
+	case "char", "varchar", "character", "character varying", "nchar", "nvarchar":
+		return true
+	default:
+	}
+	return false
+}
+
+// setLength keeps the declared length of a character column as its Max,
+// which is checked in validation and gives the length of VARCHAR in DDL.
+//
+func (self *Col) setLength(length int64) {
+	if length > 0 {
+		max := float64(length)
+		self.Max = &max
+	}
+}
+
+// sqliteLength returns the length in a declared type like VARCHAR(8).
+//
+func sqliteLength(declared string) int64 {
+	t := strings.ToUpper(declared)
+	if !strings.Contains(t, "CHAR") {
+		return 0
+	}
+	i, j := strings.Index(t, "("), strings.Index(t, ")")
+	if i < 0 || j < i {
+		return 0
+	}
+	length, err := strconv.ParseInt(strings.TrimSpace(t[i+1:j]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return length
+}
+
+// sqliteLabelType translates a declared SQLite type by its affinity.
+//
+func sqliteLabelType(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "BIGINT"):
+		return "int64"
+	case strings.Contains(t, "BOOL"):
+		return "bool"
+	case strings.Contains(t, "INT"):
+		return "int"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "string"
+	case strings.Contains(t, "BLOB"):
+		return "[]byte"
+	case strings.Contains(t, "JSON"):
+		return "json"
+	case strings.Contains(t, "UUID"):
+		return "uuid"
+	case strings.Contains(t, "DECIMAL"), strings.Contains(t, "NUMERIC"):
+		return "decimal"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "float64"
+	case strings.Contains(t, "DATE"), strings.Contains(t, "TIME"):
+		return "time"
+	default:
+	}
+	return "string"
+}
+
+func (self *DBI) introspectSQLite(ctx context.Context) ([]*Table, error) {
+	lists := make([]map[string]interface{}, 0)
+	if err := self.SelectSQLContext(ctx, &lists, `SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name`, []interface{}{[2]string{"name", "string"}}); err != nil {
+		return nil, err
+	}
+
+	var tables []*Table
+	for _, item := range lists {
+		name := item["name"].(string)
+		table := &Table{TableName: name}
+		table.SetQuestionNumber(SQLite)
+
+		cols := make([]map[string]interface{}, 0)
+		labels := []interface{}{[2]string{"cid", "int"}, [2]string{"name", "string"}, [2]string{"type", "string"}, [2]string{"notnull", "bool"}, "dflt", [2]string{"pk", "int"}}
+		if err := self.SelectSQLContext(ctx, &cols, `PRAGMA table_info(`+QuoteIdentifier(name, SQLite)+`)`, labels); err != nil {
+			return nil, err
+		}
+		pks := make(map[int]string)
+		for _, c := range cols {
+			column := c["name"].(string)
+			declared, _ := c["type"].(string)
+			notnull, _ := c["notnull"].(bool)
+			col := &Col{ColumnName: column, Label: column, TypeName: sqliteLabelType(declared), Notnull: notnull}
+			col.setLength(sqliteLength(declared))
+			table.Columns = append(table.Columns, col)
+			if pk := c["pk"].(int); pk > 0 {
+				pks[pk] = column
+			}
+		}
+		for i := 1; i <= len(pks); i++ {
+			table.Pks = append(table.Pks, pks[i])
+		}
+		// a single INTEGER PRIMARY KEY is the alias of rowid
+		if len(table.Pks) == 1 {
+			for _, c := range cols {
+				if c["name"] == table.Pks[0] && strings.ToUpper(fmt.Sprint(c["type"])) == "INTEGER" {
+					table.IdAuto = table.Pks[0]
+					for _, col := range table.Columns {
+						if col.ColumnName == table.IdAuto {
+							col.Auto = true
+						}
+					}
+				}
+			}
+		}
+
+		fks := make([]map[string]interface{}, 0)
+		labels = []interface{}{[2]string{"id", "int"}, [2]string{"seq", "int"}, [2]string{"table", "string"}, [2]string{"from", "string"}, [2]string{"to", "string"}, "onUpdate", "onDelete", "match"}
+		if err := self.SelectSQLContext(ctx, &fks, `PRAGMA foreign_key_list(`+QuoteIdentifier(name, SQLite)+`)`, labels); err != nil {
+			return nil, err
+		}
+		for _, fk := range fks {
+			to, _ := fk["to"].(string)
+			table.Fks = append(table.Fks, &Fk{FkTable: fk["table"].(string), FkColumn: to, Column: fk["from"].(string)})
+		}
+
+		indexes := make([]map[string]interface{}, 0)
+		labels = []interface{}{[2]string{"seq", "int"}, [2]string{"name", "string"}, [2]string{"unique", "bool"}, [2]string{"origin", "string"}, "partial"}
+		if err := self.SelectSQLContext(ctx, &indexes, `PRAGMA index_list(`+QuoteIdentifier(name, SQLite)+`)`, labels); err != nil {
+			return nil, err
+		}
+		var names []string
+		origins := make(map[string]bool)
+		for _, index := range indexes {
+			if unique, _ := index["unique"].(bool); unique && index["origin"] != "pk" {
+				names = append(names, index["name"].(string))
+				origins[index["name"].(string)] = index["origin"] == "u"
+			}
+		}
+		sort.Strings(names)
+		if names != nil {
+			table.uniqueIndex = names[0]
+			table.uniqueConstraint = origins[names[0]]
+			columns := make([]map[string]interface{}, 0)
+			labels = []interface{}{[2]string{"seqno", "int"}, [2]string{"cid", "int"}, [2]string{"name", "string"}}
+			if err := self.SelectSQLContext(ctx, &columns, `PRAGMA index_info(`+QuoteIdentifier(names[0], SQLite)+`)`, labels); err != nil {
+				return nil, err
+			}
+			for _, c := range columns {
+				table.Uniques = append(table.Uniques, c["name"].(string))
+			}
+		}
+
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// DefaultModel returns a model on table with the default CRUD actions:
+// insert, update, edit, topics and delete, plus insupd if Uniques exists.
+//
+func DefaultModel(t *Table) *Model {
+	actions := []Capability{
+		&Insert{Action: Action{ActionName: "insert", IsDo: true}},
+		&Update{Action: Action{ActionName: "update", IsDo: true}},
+	}
+	if t.Uniques != nil {
+		actions = append(actions, &Insupd{Action: Action{ActionName: "insupd", IsDo: true}})
+	}
+	actions = append(actions,
+		&Edit{Action: Action{ActionName: "edit"}},
+		&Topics{Action: Action{ActionName: "topics"}},
+		&Delete{Action: Action{ActionName: "delete"}})
+	return &Model{Table: *t, Actions: actions}
+}
+
+// DefaultGraph returns a graph of the default models on tables. For each
+// foreign key, the edit action of the referenced table gets a nextpage to
+// the topics action of the referencing table.
+//
+func DefaultGraph(tables []*Table) *Graph {
+	var models []Navigate
+	for _, t := range tables {
+		models = append(models, DefaultModel(t))
+	}
+
+	for _, t := range tables {
+		for _, fk := range t.Fks {
+			for _, model := range models {
+				if model.GetTable().TableName != fk.FkTable {
+					continue
+				}
+				edit := model.GetAction("edit")
+				edit.SetNextpages(append(edit.GetNextpages(), &Connection{
+					TableName:   t.TableName,
+					ActionName:  "topics",
+					RelateExtra: map[string]string{fk.FkColumn: fk.Column},
+				}))
+			}
+		}
+	}
+
+	graph := &Graph{Models: models}
+	if tables != nil {
+		graph.SetQuestionNumber(tables[0].questionNumber)
+	}
+	return graph
+}
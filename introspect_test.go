@@ -0,0 +1,108 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestIntrospectSQLite(t *testing.T) {
+	db := opensqlite(t, ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	for _, str := range []string{
+		`CREATE TABLE m_a (id INTEGER PRIMARY KEY, x VARCHAR(8) NOT NULL, y VARCHAR(8) NOT NULL, z TEXT, UNIQUE (x, y))`,
+		`CREATE TABLE m_b (tid INTEGER PRIMARY KEY, child VARCHAR(8), price REAL, id INTEGER NOT NULL REFERENCES m_a (id))`,
+	} {
+		if _, err := db.Exec(str); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tables, err := IntrospectContext(ctx, db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("%#v", tables)
+	}
+	a, b := tables[0], tables[1]
+	if a.TableName != "m_a" || a.IdAuto != "id" || a.Pks[0] != "id" || len(a.Uniques) != 2 || a.Uniques[0] != "x" || a.Uniques[1] != "y" {
+		t.Errorf("%#v", a)
+	}
+	if len(a.Columns) != 4 || a.Columns[0].TypeName != "int" || !a.Columns[0].Auto || a.Columns[1].TypeName != "string" || !a.Columns[1].Notnull || a.Columns[3].Notnull {
+		t.Errorf("%#v %#v", a.Columns[0], a.Columns[1])
+	}
+	// the length of VARCHAR(8) is kept, and so it is in DDL
+	if a.Columns[1].Max == nil || *a.Columns[1].Max != 8 || a.Columns[3].Max != nil || columnType(a.Columns[1], MySQL) != "VARCHAR(8)" {
+		t.Errorf("%#v %#v", a.Columns[1], a.Columns[3])
+	}
+	if b.TableName != "m_b" || len(b.Fks) != 1 || b.Fks[0].FkTable != "m_a" || b.Fks[0].FkColumn != "id" || b.Fks[0].Column != "id" || b.Columns[2].TypeName != "float64" || b.Uniques != nil {
+		t.Errorf("%#v", b)
+	}
+
+	if tables, err = IntrospectContext(ctx, db, SQLite, "m_b"); err != nil || len(tables) != 1 {
+		t.Errorf("%#v %v", tables, err)
+	}
+	if _, err = IntrospectContext(ctx, db, SQLite, "m_c"); err == nil {
+		t.Errorf("missing table accepted")
+	}
+
+	graph := DefaultGraph([]*Table{a, b})
+	dat, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err = NewGraphJson(dat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := graph.GetModel("m_a").GetAction("edit")
+	if len(edit.GetNextpages()) != 1 || edit.GetNextpages()[0].TableName != "m_b" || edit.GetNextpages()[0].RelateExtra["id"] != "id" {
+		t.Errorf("%s", dat)
+	}
+	if graph.GetModel("m_a").GetAction("insupd") == nil || graph.GetModel("m_b").GetAction("insupd") != nil {
+		t.Errorf("%s", dat)
+	}
+}
+
+func TestPostgresLabelType(t *testing.T) {
+	for _, c := range [][3]string{
+		{"integer", "int4", "int"},
+		{"bigint", "int8", "int64"},
+		{"character varying", "varchar", "string"},
+		{"timestamp with time zone", "timestamptz", "timestamptz"},
+		{"array", "_int4", "[]int64"},
+		{"array", "_text", "[]string"},
+		{"array", "_bool", "[]bool"},
+	} {
+		if typeName := postgresSchema.labelType(c[0], c[1]); typeName != c[2] {
+			t.Errorf("%s %s: %s", c[0], c[1], typeName)
+		}
+	}
+}
+
+func TestMysqlLabelType(t *testing.T) {
+	for _, c := range [][3]string{
+		{"int", "int(11) unsigned", "int64"},
+		{"tinyint", "tinyint(1)", "bool"},
+		{"varchar", "varchar(64)", "string"},
+		{"date", "date", "date"},
+		{"datetime", "datetime", "time"},
+		{"timestamp", "timestamp", "timestamptz"},
+	} {
+		if typeName := mysqlSchema.labelType(c[0], c[1]); typeName != c[2] {
+			t.Errorf("%s %s: %s", c[0], c[1], typeName)
+		}
+	}
+}
+
+func TestSqliteLength(t *testing.T) {
+	for declared, length := range map[string]int64{"VARCHAR(8)": 8, "character ( 20 )": 20, "TEXT": 0, "DECIMAL(10,2)": 0, "NVARCHAR": 0} {
+		if n := sqliteLength(declared); n != length {
+			t.Errorf("%s: %d", declared, n)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultMaxDepth is the maximum depth of prepares and nextpages, if not
+// set by SetMaxDepth.
+//
+const defaultMaxDepth = 64
+
+// runState is the state of a run, shared by all its prepares and nextpages:
+// the path of models and actions to the current one, and the rows so far.
+//
+type runState struct {
+	path []string
+	rows *int64
+}
+
+type runStateKey struct{}
+
+// SetMaxDepth sets the maximum depth of prepares and nextpages in a run,
+// counting the action of RunContext as 1. 0 means the default, 64, and a
+// negative n means no limit.
+//
+func (self *Graph) SetMaxDepth(n int) {
+	self.maxDepth = n
+}
+
+// SetMaxRows sets the maximum number of rows output by all actions in a
+// run, including its prepares and nextpages. 0, the default, means no limit.
+// In StreamContext, the streamed rows are not counted.
+//
+func (self *Graph) SetMaxRows(n int) {
+	self.maxRows = n
+}
+
+// enter returns ctx with model and action added to the path of the run,
+// or an ErrLimit error if the path is deeper than the maximum.
+//
+func (self *Graph) enter(ctx context.Context, model, action string) (context.Context, error) {
+	next := &runState{rows: new(int64)}
+	if state, ok := ctx.Value(runStateKey{}).(*runState); ok {
+		next.path = append(next.path, state.path...)
+		next.rows = state.rows
+	}
+	next.path = append(next.path, model+" "+action)
+
+	max := self.maxDepth
+	if max == 0 {
+		max = defaultMaxDepth
+	}
+	if max > 0 && len(next.path) > max {
+		return nil, wrapError(newError(ErrLimit, "", "depth exceeds %d: %s", max, strings.Join(next.path, " > ")), model, action)
+	}
+	return context.WithValue(ctx, runStateKey{}, next), nil
+}
+
+// count adds n rows to the run, and returns an ErrLimit error if the rows
+// are more than the maximum.
+//
+func (self *Graph) count(ctx context.Context, model, action string, n int) error {
+	state, ok := ctx.Value(runStateKey{}).(*runState)
+	if !ok || self.maxRows <= 0 {
+		return nil
+	}
+	if total := atomic.AddInt64(state.rows, int64(n)); total > int64(self.maxRows) {
+		return wrapError(newError(ErrLimit, "", "rows exceed %d: %s", self.maxRows, strings.Join(state.path, " > ")), model, action)
+	}
+	return nil
+}
+
+// CheckCycles finds the loops of prepares and nextpages in the graph, such
+// as m_a topics > m_b topics > m_a topics, which run until the maximum depth
+// unless the data ends them. Each loop is reported in a *ValidationError,
+// with the JSON path of the connection closing it as Field.
+//
+func (self *Graph) CheckCycles() error {
+	type node struct {
+		model  string
+		action string
+	}
+	type edge struct {
+		to   node
+		path string
+	}
+
+	edges := make(map[node][]edge)
+	var nodes []node
+	for i, item := range self.Models {
+		model, ok := item.(*Model)
+		if !ok {
+			continue
+		}
+		for j, action := range model.Actions {
+			from := node{model.TableName, action.GetActionName()}
+			nodes = append(nodes, from)
+			for k, p := range action.GetPrepares() {
+				edges[from] = append(edges[from], edge{node{p.TableName, p.ActionName}, fmt.Sprintf("models[%d].actions[%d].prepares[%d]", i, j, k)})
+			}
+			for k, p := range action.GetNextpages() {
+				edges[from] = append(edges[from], edge{node{p.TableName, p.ActionName}, fmt.Sprintf("models[%d].actions[%d].nextpages[%d]", i, j, k)})
+			}
+		}
+	}
+
+	// depth-first search, where a connection back to the stack closes a loop
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[node]int)
+	var stack []node
+	var fields []*FieldError
+	var visit func(n node)
+	visit = func(n node) {
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, e := range edges[n] {
+			switch state[e.to] {
+			case visiting:
+				var names []string
+				found := false
+				for _, s := range stack {
+					if s == e.to {
+						found = true
+					}
+					if found {
+						names = append(names, s.model+" "+s.action)
+					}
+				}
+				names = append(names, e.to.model+" "+e.to.action)
+				fields = append(fields, &FieldError{Field: e.path, Value: names, Message: "cycle " + strings.Join(names, " > ")})
+			case unvisited:
+				visit(e.to)
+			default:
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = visited
+	}
+	for _, n := range nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	if fields == nil {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
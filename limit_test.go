@@ -0,0 +1,73 @@
+package godbi
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimit(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = graph.CheckCycles(); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"],
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "nextpages":[{"tableName":"m_b", "actionName":"topics", "relateExtra":{"id":"id"}}]}, {"actionName":"edit"}]},
+{"tableName":"m_b", "pks":["tid"], "columns":[{"columnName":"tid", "label":"tid", "typeName":"int"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "nextpages":[{"tableName":"m_a", "actionName":"edit", "relateArgs":{"id":"id"}}, {"tableName":"m_a", "actionName":"topics", "relateExtra":{"id":"id"}}]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = graph.CheckCycles()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "models[1].actions[0].nextpages[1]" || verr.Fields[0].Message != "cycle m_a topics > m_b topics > m_a topics" {
+		t.Errorf("%v", err)
+	}
+
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+	for _, x := range []string{"a", "b", "c"} {
+		db.Exec(`INSERT INTO m_a (x, y) VALUES (?, 'y')`, x)
+	}
+	db.Exec(`INSERT INTO m_b (id, child) VALUES (1, 'c')`)
+
+	// the loop ends at the default depth
+	_, err = graph.RunContext(ctx, db, "m_a", "topics")
+	if !errors.Is(err, ErrLimit) || !strings.Contains(err.Error(), "depth exceeds 64") {
+		t.Errorf("%v", err)
+	}
+
+	graph.SetMaxDepth(3)
+	_, err = graph.RunContext(ctx, db, "m_a", "topics")
+	var e *Error
+	if !errors.As(err, &e) || e.Model != "m_b" || err.Error() != "m_b topics: depth exceeds 3: m_a topics > m_b topics > m_a topics > m_b topics" {
+		t.Errorf("%v", err)
+	}
+
+	// no limit of rows by default, and one for the whole run
+	graph.SetMaxDepth(2)
+	graph.Models[1].(*Model).Actions[0].(*Topics).Nextpages = nil
+	lists, err := graph.RunContext(ctx, db, "m_a", "topics")
+	if err != nil || len(lists) != 3 {
+		t.Errorf("%v", err)
+	}
+	graph.SetMaxRows(4)
+	if _, err = graph.RunContext(ctx, db, "m_a", "topics"); err != nil {
+		t.Errorf("%v", err)
+	}
+	graph.SetMaxRows(3)
+	_, err = graph.RunContext(ctx, db, "m_a", "topics")
+	if !errors.Is(err, ErrLimit) || err.Error() != "m_b topics: rows exceed 3: m_a topics > m_b topics" {
+		t.Errorf("%v", err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
@@ -0,0 +1,388 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration is one planned change of the database schema.
+//
+type Migration struct {
+	TableName string `json:"tableName"`
+	Change    string `json:"change"`
+	Statement string `json:"statement"`
+}
+
+// Migrator plans and applies the changes that bring the live schema
+// in line with the tables of a graph.
+//
+type Migrator struct {
+	// DBType: the database dialect
+	DBType DBType
+	// Drop: also drop the columns, and the tables, not in the graph
+	Drop bool
+	// TrackingTable: the table recording applied migrations, default "godbi_migrations"
+	TrackingTable string
+}
+
+func (self *Migrator) trackingTable() string {
+	if self.TrackingTable == "" {
+		return "godbi_migrations"
+	}
+	return self.TrackingTable
+}
+
+// typeClass returns the class of a label or column type, so that
+// equivalent types, such as "int" and "INTEGER", are not seen as changes.
+//
+func typeClass(typeName string, dialect DBType) string {
+	label := typeName
+	if _, ok := sqlTypes[typeName]; !ok {
+		lower := strings.ToLower(typeName)
+		base := lower
+		if i := strings.Index(base, "("); i >= 0 {
+			base = base[:i]
+		}
+		base = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(base), "unsigned"))
+		switch {
+		case dialect == Postgres && (strings.HasPrefix(base, "timestamp") || base == "date"):
+			label = "time"
+		case dialect == Postgres:
+			label = postgresSchema.labelType(base, lower)
+		case dialect == SQLite:
+			label = sqliteLabelType(lower)
+		default:
+			label = mysqlSchema.labelType(base, lower)
+		}
+	}
+
+	switch label {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "integer"
+	case "float32", "float64":
+		return "float"
+	case "decimal", "big.Rat":
+		return "decimal"
+	case "time", "date", "timestamp", "timestamptz":
+		if dialect == MySQL {
+			return "text"
+		}
+		return "time"
+	case "bool", "[]byte":
+		return label
+	default:
+	}
+	return "text"
+}
+
+// Plan returns the ordered statements that change the 'live' tables into
+// 'tables': new tables first, then the changed columns, the indexes, the
+// foreign keys, and the drops last. SQLite, which cannot alter columns,
+// rebuilds the table instead.
+//
+func (self *Migrator) Plan(tables, live []*Table) ([]*Migration, error) {
+	dialect, err := ddlDialect(self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	findTable := func(ts []*Table, name string) *Table {
+		for _, t := range ts {
+			if t.TableName == name {
+				return t
+			}
+		}
+		return nil
+	}
+	findCol := func(t *Table, name string) *Col {
+		for _, col := range t.Columns {
+			if col.ColumnName == name {
+				return col
+			}
+		}
+		return nil
+	}
+
+	var creates, columns, indexes, fks, drops []*Migration
+	add := func(list *[]*Migration, table, change string, statements ...string) {
+		for _, str := range statements {
+			*list = append(*list, &Migration{TableName: table, Change: change, Statement: str})
+		}
+	}
+
+	for _, t := range tables {
+		old := findTable(live, t.TableName)
+		if old == nil {
+			str, err := t.CreateTable(self.DBType)
+			if err != nil {
+				return nil, err
+			}
+			add(&creates, t.TableName, "create table", str)
+			strs, err := t.CreateIndexes(self.DBType)
+			if err != nil {
+				return nil, err
+			}
+			add(&indexes, t.TableName, "create index", strs...)
+			if strs, err = t.ForeignKeys(self.DBType); err != nil {
+				return nil, err
+			}
+			add(&fks, t.TableName, "add foreign key", strs...)
+			continue
+		}
+
+		if strings.Join(t.Pks, ",") != strings.Join(old.Pks, ",") {
+			return nil, fmt.Errorf("change of primary key in %s not supported", t.TableName)
+		}
+
+		rebuild := false
+		var altered []*Migration
+		for _, col := range t.Columns {
+			oldCol := findCol(old, col.ColumnName)
+			if oldCol == nil {
+				add(&altered, t.TableName, "add column", "ALTER TABLE "+t.TableName+" ADD COLUMN "+t.columnDefinition(col, self.DBType, dialect))
+				continue
+			}
+			typeChanged := typeClass(col.TypeName, dialect) != typeClass(oldCol.TypeName, dialect)
+			notnull := col.Notnull || grep(t.Pks, col.ColumnName)
+			nullChanged := notnull != oldCol.Notnull && !t.isAuto(col)
+			if !typeChanged && !nullChanged {
+				continue
+			}
+			switch dialect {
+			case SQLite:
+				rebuild = true
+			case Postgres:
+				if typeChanged {
+					add(&altered, t.TableName, "alter column", "ALTER TABLE "+t.TableName+" ALTER COLUMN "+col.ColumnName+" TYPE "+columnType(col, dialect))
+				}
+				if nullChanged && notnull {
+					add(&altered, t.TableName, "alter column", "ALTER TABLE "+t.TableName+" ALTER COLUMN "+col.ColumnName+" SET NOT NULL")
+				} else if nullChanged {
+					add(&altered, t.TableName, "alter column", "ALTER TABLE "+t.TableName+" ALTER COLUMN "+col.ColumnName+" DROP NOT NULL")
+				}
+			default:
+				add(&altered, t.TableName, "alter column", "ALTER TABLE "+t.TableName+" MODIFY COLUMN "+t.columnDefinition(col, self.DBType, dialect))
+			}
+		}
+
+		var newFks []*Fk
+		for _, fk := range t.Fks {
+			found := false
+			for _, oldFk := range old.Fks {
+				if oldFk.Column == fk.Column && oldFk.FkTable == fk.FkTable && oldFk.FkColumn == fk.FkColumn {
+					found = true
+					break
+				}
+			}
+			if !found && fk.FkTable != "" {
+				newFks = append(newFks, fk)
+			}
+		}
+		if newFks != nil && dialect == SQLite {
+			rebuild = true
+		}
+		uniqueChanged := t.Uniques != nil && strings.Join(t.Uniques, ",") != strings.Join(old.Uniques, ",")
+		if uniqueChanged && old.uniqueConstraint && dialect == SQLite {
+			rebuild = true // the index of a UNIQUE constraint can not be dropped
+		}
+
+		var droppedCols []string
+		if self.Drop {
+			for _, oldCol := range old.Columns {
+				if findCol(t, oldCol.ColumnName) == nil {
+					droppedCols = append(droppedCols, oldCol.ColumnName)
+				}
+			}
+		}
+
+		if rebuild {
+			strs, err := self.rebuild(t, old, droppedCols)
+			if err != nil {
+				return nil, err
+			}
+			add(&columns, t.TableName, "rebuild table", strs...)
+			continue
+		}
+		columns = append(columns, altered...)
+		for _, name := range droppedCols {
+			add(&drops, t.TableName, "drop column", "ALTER TABLE "+t.TableName+" DROP COLUMN "+name)
+		}
+
+		if uniqueChanged {
+			if old.Uniques != nil {
+				add(&indexes, t.TableName, "drop index", old.dropUnique(dialect))
+			}
+			renamed := *t
+			renamed.uniqueIndex = ""
+			add(&indexes, t.TableName, "create index", "CREATE UNIQUE INDEX "+renamed.uniqueIndexName()+" ON "+t.TableName+" ("+strings.Join(t.Uniques, ", ")+")")
+		}
+		for _, fk := range newFks {
+			add(&indexes, t.TableName, "create index", "CREATE INDEX "+t.TableName+"_"+fk.Column+"_idx ON "+t.TableName+" ("+fk.Column+")")
+			add(&fks, t.TableName, "add foreign key", "ALTER TABLE "+t.TableName+" ADD CONSTRAINT "+t.TableName+"_"+fk.Column+"_fk FOREIGN KEY ("+fk.Column+") REFERENCES "+fk.FkTable+" ("+fk.FkColumn+")")
+		}
+	}
+
+	if self.Drop {
+		var names []string
+		for _, old := range live {
+			if old.TableName != self.trackingTable() && findTable(tables, old.TableName) == nil {
+				names = append(names, old.TableName)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(&drops, name, "drop table", "DROP TABLE "+name)
+		}
+	}
+
+	var outs []*Migration
+	for _, list := range [][]*Migration{creates, columns, indexes, fks, drops} {
+		outs = append(outs, list...)
+	}
+	return outs, nil
+}
+
+// rebuild returns the statements to rebuild table 'old' as 't' in SQLite:
+// create the new table, copy the common columns, drop the old table,
+// rename the new one and create its indexes. The columns only in 'old'
+// are kept, unless in 'dropped'.
+//
+func (self *Migrator) rebuild(t, old *Table, dropped []string) ([]string, error) {
+	tmp := *t
+	tmp.TableName = t.TableName + "_godbi_new"
+	tmp.uniqueIndex = ""
+	tmp.Columns = append([]*Col(nil), t.Columns...)
+
+	var common []string
+	for _, oldCol := range old.Columns {
+		found := false
+		for _, col := range t.Columns {
+			if oldCol.ColumnName == col.ColumnName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if grep(dropped, oldCol.ColumnName) {
+				continue
+			}
+			tmp.Columns = append(tmp.Columns, oldCol)
+		}
+		common = append(common, oldCol.ColumnName)
+	}
+	create, err := tmp.CreateTable(self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	strs := []string{
+		create,
+		"INSERT INTO " + tmp.TableName + " (" + strings.Join(common, ", ") + ") SELECT " + strings.Join(common, ", ") + " FROM " + t.TableName,
+		"DROP TABLE " + t.TableName,
+		"ALTER TABLE " + tmp.TableName + " RENAME TO " + t.TableName,
+	}
+	tmp.TableName = t.TableName
+	indexes, err := tmp.CreateIndexes(self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	return append(strs, indexes...), nil
+}
+
+// PlanContext compares the tables of graph with the live schema of db,
+// and returns the planned migrations, without running any of them.
+//
+func (self *Migrator) PlanContext(ctx context.Context, db Executor, graph *Graph) ([]*Migration, error) {
+	live, err := IntrospectContext(ctx, db, self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	var tables []*Table
+	for _, model := range graph.Models {
+		tables = append(tables, model.GetTable())
+	}
+	return self.Plan(tables, live)
+}
+
+// ApplyContext plans the migrations and runs them in one transaction, if
+// the database supports it, recording each statement in the tracking table
+// under the same version. It returns the applied migrations.
+//
+// MySQL commits each DDL statement implicitly, so the statements are run
+// one by one there, as in any DBType of MySQL DDL, and, on an error, the migrations applied before it are
+// returned with the error, to report the partial state.
+//
+func (self *Migrator) ApplyContext(ctx context.Context, db Executor, graph *Graph) ([]*Migration, error) {
+	migrations, err := self.PlanContext(ctx, db, graph)
+	if err != nil || migrations == nil {
+		return migrations, err
+	}
+
+	tracking := &Table{TableName: self.trackingTable(), Pks: []string{"id"}, IdAuto: "id", Columns: []*Col{
+		{ColumnName: "id", TypeName: "int", Notnull: true, Auto: true},
+		{ColumnName: "version", TypeName: "string", Notnull: true},
+		{ColumnName: "statement", TypeName: "TEXT", Notnull: true},
+		{ColumnName: "applied_at", TypeName: "time", Notnull: true}}}
+	create, err := tracking.CreateTable(self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	create = "CREATE TABLE IF NOT EXISTS " + strings.TrimPrefix(create, "CREATE TABLE ")
+	record := "INSERT INTO " + tracking.TableName + " (version, statement, applied_at) VALUES (?, ?, ?)"
+	record = questionMarker(record, self.DBType)
+
+	var applied []*Migration
+	run := func(exec Executor) error {
+		dbi := &DBI{Executor: exec}
+		if err := dbi.DoSQLContext(ctx, create); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		version := now.Format("20060102150405")
+		for _, m := range migrations {
+			if err := dbi.DoSQLContext(ctx, m.Statement); err != nil {
+				return fmt.Errorf("%s: %w", m.Statement, err)
+			}
+			if err := dbi.DoSQLContext(ctx, record, version, m.Statement, now); err != nil {
+				return err
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	}
+
+	dialect, err := ddlDialect(self.DBType)
+	if err != nil {
+		return nil, err
+	}
+	beginner, ok := db.(txBeginner)
+	if !ok || dialect == MySQL {
+		if err = run(db); err != nil {
+			return applied, err
+		}
+		return migrations, nil
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = run(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return nil, fmt.Errorf("error original: %w, rollback: %v", err, rollbackErr)
+		}
+		return nil, err
+	}
+	return migrations, tx.Commit()
+}
+
+// MigrationReport returns the dry-run report of migrations, one
+// commented statement per change.
+//
+func MigrationReport(migrations []*Migration) string {
+	var lines []string
+	for _, m := range migrations {
+		lines = append(lines, "-- "+m.TableName+": "+m.Change+"\n"+m.Statement+";")
+	}
+	return strings.Join(lines, "\n\n")
+}
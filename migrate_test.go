@@ -0,0 +1,156 @@
+package godbi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func migrateTables() []*Table {
+	return []*Table{
+		{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Columns: []*Col{
+			{ColumnName: "id", TypeName: "int", Notnull: true, Auto: true},
+			{ColumnName: "x", TypeName: "string", Notnull: true},
+			{ColumnName: "y", TypeName: "int64"}}},
+		{TableName: "m_b", Pks: []string{"tid"}, IdAuto: "tid", Columns: []*Col{
+			{ColumnName: "tid", TypeName: "int", Notnull: true, Auto: true},
+			{ColumnName: "child", TypeName: "string"},
+			{ColumnName: "id", TypeName: "int", Notnull: true}},
+			Fks: []*Fk{{FkTable: "m_a", FkColumn: "id", Column: "id"}}},
+	}
+}
+
+func TestMigratePlan(t *testing.T) {
+	live := []*Table{
+		{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Columns: []*Col{
+			{ColumnName: "id", TypeName: "int", Notnull: true, Auto: true},
+			{ColumnName: "x", TypeName: "string"},
+			{ColumnName: "z", TypeName: "string"}}},
+		{TableName: "m_old", Pks: []string{"id"}, Columns: []*Col{
+			{ColumnName: "id", TypeName: "int", Notnull: true}}},
+	}
+
+	migrator := &Migrator{DBType: MySQL, Drop: true}
+	migrations, err := migrator.Plan(migrateTables(), live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	for _, m := range migrations {
+		strs = append(strs, m.Statement)
+	}
+	if len(strs) != 7 ||
+		!strings.HasPrefix(strs[0], "CREATE TABLE m_b (") ||
+		strs[1] != "ALTER TABLE m_a MODIFY COLUMN x VARCHAR(255) NOT NULL" ||
+		strs[2] != "ALTER TABLE m_a ADD COLUMN y BIGINT" ||
+		strs[3] != "CREATE INDEX m_b_id_idx ON m_b (id)" ||
+		strs[4] != "ALTER TABLE m_b ADD CONSTRAINT m_b_id_fk FOREIGN KEY (id) REFERENCES m_a (id)" ||
+		strs[5] != "ALTER TABLE m_a DROP COLUMN z" ||
+		strs[6] != "DROP TABLE m_old" {
+		t.Errorf("%#v", strs)
+	}
+
+	migrator = &Migrator{DBType: Postgres}
+	if migrations, err = migrator.Plan(migrateTables(), live); err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 5 ||
+		migrations[1].Statement != "ALTER TABLE m_a ALTER COLUMN x SET NOT NULL" ||
+		migrations[2].Statement != "ALTER TABLE m_a ADD COLUMN y BIGINT" ||
+		migrations[2].Change != "add column" {
+		t.Errorf("%s", MigrationReport(migrations))
+	}
+
+	if migrations, err = migrator.Plan(live[:1], live[:1]); err != nil || migrations != nil {
+		t.Errorf("%#v %v", migrations, err)
+	}
+
+	// a changed unique index is dropped before the new one is created
+	old := &Table{TableName: "m_a", Pks: []string{"id"}, Uniques: []string{"x"}, Columns: live[0].Columns}
+	unique := &Table{TableName: "m_a", Pks: []string{"id"}, Uniques: []string{"x", "z"}, Columns: live[0].Columns}
+	for dbType, drop := range map[DBType]string{MySQL: "DROP INDEX m_a_x_uniq ON m_a", Postgres: "DROP INDEX m_a_x_uniq"} {
+		migrator = &Migrator{DBType: dbType}
+		migrations, err = migrator.Plan([]*Table{unique}, []*Table{old})
+		if err != nil || len(migrations) != 2 || migrations[0].Statement != drop || migrations[1].Statement != "CREATE UNIQUE INDEX m_a_x_z_uniq ON m_a (x, z)" {
+			t.Errorf("%d: %s %v", dbType, MigrationReport(migrations), err)
+		}
+	}
+	old.uniqueIndex, old.uniqueConstraint = "m_a_x_key", true
+	migrator = &Migrator{DBType: Postgres}
+	if migrations, err = migrator.Plan([]*Table{unique}, []*Table{old}); err != nil || len(migrations) != 2 || migrations[0].Statement != "ALTER TABLE m_a DROP CONSTRAINT m_a_x_key" {
+		t.Errorf("%s %v", MigrationReport(migrations), err)
+	}
+
+	changed := []*Table{{TableName: "m_a", Pks: []string{"x"}, Columns: live[0].Columns}}
+	if _, err = migrator.Plan(changed, live); err == nil {
+		t.Errorf("primary key change expected to fail")
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db := opensqlite(t, ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	for _, str := range []string{
+		`CREATE TABLE m_a (id INTEGER PRIMARY KEY AUTOINCREMENT, x TEXT, z TEXT, UNIQUE (z))`,
+		`INSERT INTO m_a (x, z) VALUES ('a', 'b')`,
+	} {
+		if _, err := db.Exec(str); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	graph := &Graph{}
+	for _, table := range migrateTables() {
+		graph.Models = append(graph.Models, DefaultModel(table))
+	}
+
+	// without Drop, the rebuild keeps the column not in the graph
+	migrator := &Migrator{DBType: SQLite}
+	migrations, err := migrator.PlanContext(ctx, db, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := MigrationReport(migrations); !strings.Contains(report, "INSERT INTO m_a_godbi_new (id, x, z) SELECT id, x, z FROM m_a;") {
+		t.Errorf("%s", report)
+	}
+
+	// a changed UNIQUE constraint rebuilds the table, with the new index
+	graph.Models[0].(*Model).Table.Uniques = []string{"x"}
+	if migrations, err = migrator.PlanContext(ctx, db, graph); err != nil {
+		t.Fatal(err)
+	}
+	if report := MigrationReport(migrations); !strings.Contains(report, "rebuild table") || !strings.Contains(report, "CREATE UNIQUE INDEX m_a_x_uniq ON m_a (x);") || strings.Contains(report, "DROP INDEX") {
+		t.Errorf("%s", report)
+	}
+	graph.Models[0].(*Model).Table.Uniques = nil
+
+	migrator = &Migrator{DBType: SQLite, Drop: true}
+	migrations, err = migrator.PlanContext(ctx, db, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := MigrationReport(migrations)
+	if !strings.HasPrefix(report, "-- m_b: create table\nCREATE TABLE m_b (") ||
+		!strings.Contains(report, "-- m_a: rebuild table\nINSERT INTO m_a_godbi_new (id, x) SELECT id, x FROM m_a;") {
+		t.Errorf("%s", report)
+	}
+
+	if migrations, err = migrator.ApplyContext(ctx, db, graph); err != nil {
+		t.Fatal(err)
+	}
+	if migrations, err = migrator.PlanContext(ctx, db, graph); err != nil || migrations != nil {
+		t.Errorf("%s %v", MigrationReport(migrations), err)
+	}
+
+	var x string
+	var n int
+	if err = db.QueryRow(`SELECT x FROM m_a WHERE id=1`).Scan(&x); err != nil || x != "a" {
+		t.Errorf("%s %v", x, err)
+	}
+	if err = db.QueryRow(`SELECT COUNT(*) FROM godbi_migrations`).Scan(&n); err != nil || n != 6 {
+		t.Errorf("%d %v", n, err)
+	}
+}
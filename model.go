@@ -2,8 +2,8 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 )
@@ -13,7 +13,7 @@ import (
 type Navigate interface {
 	GetTable() *Table
 	GetAction(string) Capability
-	RunModelContext(context.Context, *sql.DB, string, interface{}, ...map[string]interface{}) ([]map[string]interface{}, error)
+	RunModelContext(context.Context, Executor, string, interface{}, ...map[string]interface{}) ([]map[string]interface{}, error)
 }
 
 type Model struct {
@@ -72,6 +72,8 @@ func Assertion(actions []interface{}, custom ...Capability) ([]Capability, error
 			switch name {
 			case "insert":
 				tran = &Insert{Action:Action{IsDo:true}}
+			case "bulkinsert":
+				tran = &BulkInsert{Action:Action{IsDo:true}}
 			case "update":
 				tran = &Update{Action:Action{IsDo:true}}
 			case "insupd":
@@ -84,6 +86,10 @@ func Assertion(actions []interface{}, custom ...Capability) ([]Capability, error
 				tran = new(Delete)
 			case "delecs":
 				tran = new(Delecs)
+			case "sql":
+				tran = new(SQL)
+			case "proc":
+				tran = new(Proc)
 			default:
 				return nil, fmt.Errorf("action %s not defined", name)
 			}
@@ -112,35 +118,98 @@ func (self *Model) GetAction(action string) Capability {
 	return nil
 }
 
-func (self *Model) RunModel(db *sql.DB, action string, ARGS interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Model) RunModel(db Executor, action string, ARGS interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunModelContext(context.Background(), db, action, ARGS, extra...)
 }
 
-func (self *Model) RunModelContext(ctx context.Context, db *sql.DB, action string, ARGS interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+// RunModelContext runs the action on ARGS, a map or a slice of maps. A failure
+// is returned as *Error of the model and action.
+//
+func (self *Model) RunModelContext(ctx context.Context, db Executor, action string, ARGS interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	lists, err := self.runModelContext(ctx, db, action, ARGS, extra...)
+	if err != nil {
+		return nil, wrapError(err, self.TableName, action)
+	}
+	return lists, nil
+}
+
+func (self *Model) runModelContext(ctx context.Context, db Executor, action string, ARGS interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
     obj := self.GetAction(action)
     if obj == nil {
-        return nil, fmt.Errorf("actions or action %s is nil", action)
+        return nil, newError(ErrConfig, "", "actions or action %s is nil", action)
     }
-	if ARGS == nil {
-		return obj.RunActionContext(ctx, db, &self.Table, nil, extra...)
+	befores, afters, err := actionHooks(ctx, obj)
+	if err != nil {
+		return nil, err
 	}
 
 	switch t := ARGS.(type) {
+	case nil:
+		return self.runActionContext(ctx, db, obj, befores, afters, nil, extra...)
 	case map[string]interface{}:
-		return obj.RunActionContext(ctx, db, &self.Table, t, extra...)
+		return self.runActionContext(ctx, db, obj, befores, afters, t, extra...)
 	case []map[string]interface{}:
+		if bulk, ok := obj.(bulkRunner); ok {
+			// the before hooks of each row start from the same extra, and
+			// the extra they return applies to the row only
+			var rows, extras []map[string]interface{}
+			for _, item := range t {
+				ex := extra
+				if hasValue(extra) {
+					ex = []map[string]interface{}{CloneExtra(extra[0])}
+				}
+				row, rowExtra, err := runBefores(ctx, db, &self.Table, action, befores, item, ex...)
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, row)
+				if hasValue(rowExtra) {
+					extras = append(extras, rowExtra[0])
+				} else {
+					extras = append(extras, nil)
+				}
+			}
+			lists, err := bulk.RunBulkContext(ctx, db, &self.Table, rows, extras...)
+			if err != nil {
+				return nil, err
+			}
+			return runAfters(ctx, db, &self.Table, action, afters, lists)
+		}
+		// a missing row is skipped, unless no row is found
 		var data []map[string]interface{}
+		var missing error
+		found := false
 		for _, item := range t {
-			lists, err := obj.RunActionContext(ctx, db, &self.Table, item, extra...)
-			if err != nil {
+			lists, err := self.runActionContext(ctx, db, obj, befores, afters, item, extra...)
+			if errors.Is(err, ErrNotFound) {
+				missing = err
+				continue
+			} else if err != nil {
 				return nil, err
 			}
+			found = true
 			data = append(data, lists...)
 		}
+		if !found && missing != nil {
+			return nil, missing
+		}
 		return data, nil
 	default:
 		return nil, fmt.Errorf("wrong input data type: %#v", t)
 	}
+}
 
-	return nil, nil
+// runActionContext runs the action between its before and after hooks.
+//
+func (self *Model) runActionContext(ctx context.Context, db Executor, obj Capability, befores []BeforeHook, afters []AfterHook, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	action := obj.GetActionName()
+	ARGS, extra, err := runBefores(ctx, db, &self.Table, action, befores, ARGS, extra...)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := obj.RunActionContext(ctx, db, &self.Table, ARGS, extra...)
+	if err != nil {
+		return nil, err
+	}
+	return runAfters(ctx, db, &self.Table, action, afters, lists)
 }
@@ -2,18 +2,17 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"testing"
 )
 
-type SQL struct {
+type customSQL struct {
 	Action
 	Statement string   `json:"statement"`
 }
 
-func (self *SQL) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *customSQL) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	lists := make([]map[string]interface{}, 0)
-	dbi := &DBI{DB: db}
+	dbi := &DBI{Executor: db}
 	var names []interface{}
 	for _, col := range t.Columns {
 		names = append(names, col.ColumnName)
@@ -23,7 +22,7 @@ func (self *SQL) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARG
 }
 
 func TestModel(t *testing.T) {
-	custom := new(SQL)
+	custom := new(customSQL)
 	custom.ActionName = "sql"
 	model, err := NewModelJsonFile("model.json", custom)
 	if err != nil {
@@ -48,7 +47,7 @@ func TestModel(t *testing.T) {
 				t.Errorf("%#v", update)
 			}
 		case "sql":
-			sql := v.(*SQL)
+			sql := v.(*customSQL)
 			if model.TableName != "adv_campaign" ||
 				model.Pks[0] != "campaign_id" ||
 				sql.Nextpages[0].ActionName != "topics" ||
@@ -60,16 +59,32 @@ func TestModel(t *testing.T) {
 	}
 }
 
+func TestModelSQL(t *testing.T) {
+	model, err := NewModelJsonFile("model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, ok := model.GetAction("sql").(*SQL)
+	if !ok {
+		t.Fatalf("%#v", model.GetAction("sql"))
+	}
+	if sql.IsDo ||
+		sql.Nextpages[0].ActionName != "topics" ||
+		sql.Statement != "SELECT x, y, z FROM a WHERE b=?" {
+		t.Errorf("%#v", sql)
+	}
+}
+
 func TestModelRun(t *testing.T) {
 	db, err := getdb()
 	if err != nil {
-		panic(err)
+		skipNoDB(t, err)
 	}
 	defer db.Close()
 
 	db.Exec(`drop table if exists m_a`)
-	db.Exec(`CREATE TABLE m_a (id int auto_increment not null primary key,
-        x varchar(8), y varchar(8), z varchar(8))`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8), UNIQUE (x, y))`))
 
 	str := `{
     "tableName":"m_a",
@@ -105,6 +120,7 @@ func TestModelRun(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	model.SetQuestionNumber(testDBType)
 
 	var lists []map[string]interface{}
 	// the 1st web requests is assumed to create id=1 to the m_a table
@@ -194,13 +210,13 @@ func TestModelRun(t *testing.T) {
 func TestModelRunMultiple(t *testing.T) {
 	db, err := getdb()
 	if err != nil {
-		panic(err)
+		skipNoDB(t, err)
 	}
 	defer db.Close()
 
 	db.Exec(`drop table if exists m_a`)
-	db.Exec(`CREATE TABLE m_a (id int auto_increment not null primary key,
-        x varchar(8), y varchar(8), z varchar(8))`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8), UNIQUE (x, y))`))
 
 	str := `{
     "tableName":"m_a",
@@ -234,6 +250,7 @@ func TestModelRunMultiple(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	model.SetQuestionNumber(testDBType)
 
 	var lists []map[string]interface{}
 	// the 1st web requests is assumed to create id=1 to the m_a table
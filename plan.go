@@ -0,0 +1,271 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Step is a step of a dry run: the action on the model, with the args and
+// extra it ran with, its statements, and the steps of its prepares and
+// nextpages. Connection is the prepare or nextpage which led to the step.
+//
+type Step struct {
+	Model      string                 `json:"model"`
+	Action     string                 `json:"action"`
+	Connection *Connection            `json:"connection,omitempty"`
+	Args       interface{}            `json:"args,omitempty"`
+	Extra      map[string]interface{} `json:"extra,omitempty"`
+	Statements []*Statement           `json:"statements,omitempty"`
+	// Partial tells that the step stopped at a write, whose output is
+	// not known in a dry run, so its nextpages are not planned.
+	Partial    bool                   `json:"partial,omitempty"`
+	Prepares   []*Step                `json:"prepares,omitempty"`
+	Nextpages  []*Step                `json:"nextpages,omitempty"`
+}
+
+// Statement is a SQL statement of a step, with its bound args. A read is
+// run, and explained if asked, while a write is not.
+//
+type Statement struct {
+	SQL     string                   `json:"sql"`
+	Args    []interface{}            `json:"args,omitempty"`
+	Write   bool                     `json:"write,omitempty"`
+	Explain []map[string]interface{} `json:"explain,omitempty"`
+}
+
+// errPlanned is returned for a write in a dry run, which can not be faked.
+//
+var errPlanned = errors.New("write not run in a dry run")
+
+// planState is the state of a dry run in the context: the current step,
+// and the connection of the next step, if any. Write tells that all
+// statements of the step are writes, as in SQL and Proc not read-only.
+//
+type planState struct {
+	step    *Step
+	link    *Connection
+	prepare bool
+	write   bool
+}
+
+type planStateKey struct{}
+
+// PlanContext is a dry run of RunContext. It walks the same prepares and
+// nextpages, and returns the tree of steps, with the SQL statements of each
+// step and their bound args. The reads are run, so the nextpages are planned
+// on the real rows, but the writes are not: a write is taken as done, and
+// an insert as giving id 0, while a write read as rows, such as a bulk
+// insert with RETURNING, stops its step as Partial. The statements of SQL
+// and Proc are all taken as writes, unless the action is ReadOnly, and no
+// hook is run. If db can begin a transaction, the plan runs in one, which
+// is always rolled back.
+//
+// If 'explain' is true, the EXPLAIN of each read is added to its statement,
+// in MySQL, Postgres and SQLite.
+//
+func (self *Graph) PlanContext(ctx context.Context, db Executor, explain bool, model, action string, rest ...interface{}) ([]*Step, error) {
+	if beginner, ok := db.(txBeginner); ok {
+		tx, err := beginner.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+	root := &Step{}
+	ctx = context.WithValue(ctx, planStateKey{}, &planState{step: root})
+	planner := &planExecutor{db: db, explain: explain, dbType: self.questionNumber}
+	_, err := self.RunContext(ctx, planner, model, action, rest...)
+	return root.Nextpages, err
+}
+
+// planLink returns ctx with connection p for the next step of a dry run.
+//
+func planLink(ctx context.Context, p *Connection, prepare bool) context.Context {
+	state, ok := ctx.Value(planStateKey{}).(*planState)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, planStateKey{}, &planState{step: state.step, link: p, prepare: prepare})
+}
+
+// planStep adds the step of model and action to the dry run, and returns
+// ctx with it as the current step, or nil if not in a dry run.
+//
+func planStep(ctx context.Context, model, action string, obj Capability) (context.Context, *Step) {
+	state, ok := ctx.Value(planStateKey{}).(*planState)
+	if !ok {
+		return ctx, nil
+	}
+	step := &Step{Model: model, Action: action, Connection: state.link}
+	if state.prepare {
+		state.step.Prepares = append(state.step.Prepares, step)
+	} else {
+		state.step.Nextpages = append(state.step.Nextpages, step)
+	}
+	write := false
+	switch t := obj.(type) {
+	case *SQL:
+		write = !t.ReadOnly
+	case *Proc:
+		write = !t.ReadOnly
+	default:
+	}
+	return context.WithValue(ctx, planStateKey{}, &planState{step: step, write: write}), step
+}
+
+// planPartial tells if err is a write stopped in the dry run of ctx, and
+// marks the step so.
+//
+func planPartial(ctx context.Context, step *Step, err error) bool {
+	if step == nil || err == nil {
+		return false
+	}
+	if errors.Is(err, errPlanned) || (errors.Is(err, context.Canceled) && ctx.Err() == nil) {
+		step.Partial = true
+		return true
+	}
+	return false
+}
+
+var writeRegexp = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|REPLACE|CALL)\b`)
+
+// isRead tells if query only reads.
+//
+func isRead(query string) bool {
+	fields := strings.Fields(strings.TrimLeft(query, " \t\r\n("))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "SHOW", "DESCRIBE", "PRAGMA", "VALUES":
+		return true
+	case "WITH":
+		return !writeRegexp.MatchString(query)
+	default:
+	}
+	return false
+}
+
+// planResult is the result of a write not run.
+//
+type planResult struct{}
+
+func (self planResult) LastInsertId() (int64, error) { return 0, nil }
+
+// RowsAffected is 1, so that the run goes on as if the write was done.
+//
+func (self planResult) RowsAffected() (int64, error) { return 1, nil }
+
+// dryRunner is the Executor of a dry run, for the paths which can't go
+// through the Executor methods: a write whose id or rows are read from a
+// prepared statement, and a query which is not a statement of the plan.
+//
+type dryRunner interface {
+	Executor
+	// skipWrite records the write, without running it
+	skipWrite(ctx context.Context, query string, args []interface{})
+	// underlying returns the Executor the reads run on
+	underlying() Executor
+}
+
+// planExecutor is the Executor of a dry run, which records the statements
+// into the current step, runs the reads, and skips the writes.
+//
+type planExecutor struct {
+	db      Executor
+	explain bool
+	dbType  DBType
+}
+
+func (self *planExecutor) skipWrite(ctx context.Context, query string, args []interface{}) {
+	self.record(ctx, query, args, true)
+}
+
+func (self *planExecutor) underlying() Executor {
+	return self.db
+}
+
+// isRead tells if query only reads, in a step not taken as writes.
+//
+func (self *planExecutor) isRead(ctx context.Context, query string) bool {
+	if state, ok := ctx.Value(planStateKey{}).(*planState); ok && state.write {
+		return false
+	}
+	return isRead(query)
+}
+
+func (self *planExecutor) record(ctx context.Context, query string, args []interface{}, write bool) {
+	state, ok := ctx.Value(planStateKey{}).(*planState)
+	if !ok {
+		return
+	}
+	statement := &Statement{SQL: query, Args: args, Write: write}
+	if !write && self.explain {
+		statement.Explain = self.explainRead(ctx, query, args)
+	}
+	state.step.Statements = append(state.step.Statements, statement)
+}
+
+// explainRead returns the EXPLAIN output of a read, or nil if not supported.
+//
+func (self *planExecutor) explainRead(ctx context.Context, query string, args []interface{}) []map[string]interface{} {
+	var prefix string
+	switch self.dbType {
+	case SQLite:
+		prefix = "EXPLAIN QUERY PLAN "
+	case MySQL, Postgres:
+		prefix = "EXPLAIN "
+	default:
+		return nil
+	}
+	lists := make([]map[string]interface{}, 0)
+	dbi := &DBI{Executor: self.db}
+	if err := dbi.SelectContext(ctx, &lists, prefix+query, args...); err != nil {
+		return []map[string]interface{}{{"error": err.Error()}}
+	}
+	return lists
+}
+
+func (self *planExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if self.isRead(ctx, query) {
+		self.record(ctx, query, args, false)
+		return self.db.ExecContext(ctx, query, args...)
+	}
+	self.record(ctx, query, args, true)
+	return planResult{}, nil
+}
+
+func (self *planExecutor) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if self.isRead(ctx, query) {
+		return self.db.PrepareContext(ctx, query)
+	}
+	self.record(ctx, query, nil, true)
+	return nil, errPlanned
+}
+
+func (self *planExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if self.isRead(ctx, query) {
+		self.record(ctx, query, args, false)
+		return self.db.QueryContext(ctx, query, args...)
+	}
+	self.record(ctx, query, args, true)
+	return nil, errPlanned
+}
+
+func (self *planExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if self.isRead(ctx, query) {
+		self.record(ctx, query, args, false)
+		return self.db.QueryRowContext(ctx, query, args...)
+	}
+	self.record(ctx, query, args, true)
+	// a *sql.Row can't be made outside of database/sql, but a cancelled
+	// context gives a row of error, as the connection is not taken then
+	// and the write never sent, see TestPlan
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	return self.db.QueryRowContext(cctx, query, args...)
+}
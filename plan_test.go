@@ -0,0 +1,137 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestIsRead(t *testing.T) {
+	for query, read := range map[string]bool{
+		"SELECT x FROM m_a":                               true,
+		" (SELECT 1) UNION (SELECT 2)":                     true,
+		"WITH t AS (SELECT 1) SELECT * FROM t":             true,
+		"WITH t AS (DELETE FROM m_a RETURNING id) SELECT 1": false,
+		"INSERT INTO m_a (x) VALUES (?) RETURNING id":      false,
+		"update m_a SET x=?":                               false,
+		"":                                                 false,
+	} {
+		if isRead(query) != read {
+			t.Errorf("%s: %v", query, !read)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+
+	for _, x := range []string{"a", "b"} {
+		if _, err = graph.RunContext(ctx, db, "m_a", "insert", map[string]interface{}{"x": x, "y": "y"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps, err := graph.PlanContext(ctx, db, true, "m_a", "topics")
+	if err != nil || len(steps) != 1 {
+		t.Fatalf("%v %#v", err, steps)
+	}
+	topics := steps[0]
+	if topics.Model != "m_a" || topics.Action != "topics" || topics.Connection != nil || len(topics.Statements) != 1 {
+		t.Fatalf("%#v", topics)
+	}
+	if s := topics.Statements[0]; !strings.HasPrefix(s.SQL, "SELECT") || s.Write || len(s.Explain) == 0 {
+		t.Errorf("%#v", s)
+	}
+	// the edit of both rows is batched into one step
+	if len(topics.Nextpages) != 1 || topics.Nextpages[0].Connection.ActionName != "edit" || !strings.Contains(topics.Nextpages[0].Statements[0].SQL, "IN (") {
+		t.Fatalf("%#v", topics.Nextpages)
+	}
+	edit := topics.Nextpages[0]
+	if len(edit.Nextpages) != 1 || edit.Nextpages[0].Model != "m_b" || edit.Nextpages[0].Action != "topics" {
+		t.Errorf("%#v", edit.Nextpages)
+	}
+
+	// the writes are planned, not run
+	steps, err = graph.PlanContext(ctx, db, false, "m_a", "insert", map[string]interface{}{"x": "c", "y": "y"})
+	if err != nil || len(steps) != 1 {
+		t.Fatalf("%v %#v", err, steps)
+	}
+	insert := steps[0]
+	if s := insert.Statements[0]; !strings.HasPrefix(s.SQL, "INSERT") || !s.Write || s.Explain != nil || insert.Args.(map[string]interface{})["x"] != "c" {
+		t.Errorf("%#v", insert)
+	}
+	if len(insert.Nextpages) != 1 || insert.Nextpages[0].Model != "m_b" || !insert.Nextpages[0].Statements[0].Write {
+		t.Errorf("%#v", insert.Nextpages)
+	}
+
+	lists, err := graph.RunContext(ctx, db, "m_a", "topics")
+	if err != nil || len(lists) != 2 {
+		t.Errorf("%v %v", lists, err)
+	}
+
+	// the statements of SQL are writes, unless it is read-only, and no
+	// hook runs
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"], "idAuto":"id",
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int", "auto":true}],
+"actions":[{"actionName":"sql", "statement":"SELECT x FROM m_a", "labels":[{"columnName":"x", "label":"x", "typeName":"string"}]},
+	{"actionName":"topics"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetQuestionNumber(testDBType)
+	hooked := false
+	graph.GetModel("m_a").(*Model).AddBefore("topics", func(ctx context.Context, db Executor, t *Table, action string, ARGS, extra map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+		hooked = true
+		return ARGS, extra, nil
+	})
+	counter := &countExecutor{Executor: db}
+	steps, err = graph.PlanContext(ctx, counter, false, "m_a", "sql")
+	if err != nil || len(steps) != 1 || counter.n != 0 || !steps[0].Partial || !steps[0].Statements[0].Write {
+		t.Errorf("%d %v %#v", counter.n, err, steps)
+	}
+	graph.GetModel("m_a").GetAction("sql").(*SQL).ReadOnly = true
+	steps, err = graph.PlanContext(ctx, counter, false, "m_a", "sql")
+	if err != nil || len(steps) != 1 || counter.n != 1 || steps[0].Partial || steps[0].Statements[0].Write {
+		t.Errorf("%d %v %#v", counter.n, err, steps)
+	}
+	if _, err = graph.PlanContext(ctx, db, false, "m_a", "topics"); err != nil || hooked {
+		t.Errorf("%v %v", err, hooked)
+	}
+	if _, err = graph.RunContext(ctx, db, "m_a", "topics"); err != nil || !hooked {
+		t.Errorf("%v %v", err, hooked)
+	}
+
+	// a write read as a row is not sent, neither on the pool nor in a
+	// transaction
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	planCtx := context.WithValue(ctx, planStateKey{}, &planState{step: &Step{}})
+	for _, under := range []Executor{db, tx} {
+		var id int64
+		planner := &planExecutor{db: under, dbType: testDBType}
+		err = planner.QueryRowContext(planCtx, questionMarker(`INSERT INTO m_a (x, y) VALUES (?, ?) RETURNING id`, testDBType), "q", "q").Scan(&id)
+		if err == nil || id != 0 {
+			t.Errorf("%d %v", id, err)
+		}
+	}
+	var n int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM m_a WHERE x = 'q'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("%d %v", n, err)
+	}
+	tx.Rollback()
+	if err = db.QueryRow(`SELECT COUNT(*) FROM m_a WHERE x = 'q'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("%d %v", n, err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
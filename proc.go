@@ -0,0 +1,68 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+)
+
+// Proc runs a stored procedure declared in the model.
+//
+// Pars are the names of the IN parameters in order, whose values are taken
+// from 'extra' first and then from ARGS. Outs are the OUT parameters and
+// Labels the columns of the result set, if any, with their data types.
+// ReadOnly tells that the procedure does not write, so it is run in a dry run.
+//
+type Proc struct {
+	Action
+	ProcName string   `json:"procName" hcl:"procName"`
+	Pars     []string `json:"pars,omitempty" hcl:"pars,optional"`
+	Outs     []*Col   `json:"outs,omitempty" hcl:"outs,optional"`
+	Labels   []*Col   `json:"labels,omitempty" hcl:"labels,optional"`
+	ReadOnly bool     `json:"readOnly,omitempty" hcl:"readOnly,optional"`
+}
+
+func (self *Proc) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
+}
+
+// RunActionContext calls the procedure. If IsDo is true, the procedure is
+// run in transaction and the IN and OUT data are returned as the only row.
+// Otherwise the rows of the result set are returned, each of which also
+// carries the OUT data.
+//
+func (self *Proc) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	if self.ProcName == "" {
+		return nil, fmt.Errorf("procedure not defined in action %s", self.ActionName)
+	}
+
+	fieldValues, values, err := bindValues(self.Pars, ARGS, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	dbi := &DBI{Executor: db, DBType: t.questionNumber}
+	names := colLabels(self.Outs)
+	hash := make(map[string]interface{})
+	if self.IsDo {
+		if err = dbi.TxProcContext(ctx, hash, self.ProcName, names, values...); err != nil {
+			return nil, err
+		}
+		for k, v := range hash {
+			fieldValues[k] = v
+		}
+		return fromFv(fieldValues), nil
+	}
+
+	lists := make([]map[string]interface{}, 0)
+	if err = dbi.SelectDoProcContext(ctx, &lists, hash, names, self.ProcName, colLabels(self.Labels), values...); err != nil {
+		return nil, err
+	}
+	for _, item := range lists {
+		for k, v := range hash {
+			if _, ok := item[k]; !ok {
+				item[k] = v
+			}
+		}
+	}
+	return lists, nil
+}
@@ -0,0 +1,64 @@
+package godbi
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProc(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	skipProcedures(t)
+	ctx := context.Background()
+
+	db.Exec(`drop procedure if exists proc_w`)
+	db.Exec(`drop table if exists letters`)
+	db.Exec(`create table letters(x varchar(1))`)
+	db.Exec(`create procedure proc_w(IN x0 varchar(1),OUT y0 int) begin insert into letters values(x0); select x from letters; set y0=100; end`)
+
+	str := `{
+    "tableName":"letters",
+    "columns": [
+{"columnName":"x", "label":"x", "typeName":"string" }
+    ],
+	"actions": [
+	{
+		"actionName": "proc",
+		"procName": "proc_w",
+		"pars": ["x"],
+		"outs": [{"label":"y0", "typeName":"int64"}],
+		"labels": [{"label":"x", "typeName":"string"}]
+	}
+]}`
+	model, err := NewModelJson([]byte(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc, ok := model.GetAction("proc").(*Proc)
+	if !ok || proc.ProcName != "proc_w" || proc.Outs[0].Label != "y0" {
+		t.Fatalf("%#v", model.GetAction("proc"))
+	}
+
+	lists, err := model.RunModelContext(ctx, db, "proc", map[string]interface{}{"x": "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["x"] != "m" || lists[0]["y0"].(int64) != 100 {
+		t.Errorf("%v", lists)
+	}
+
+	proc.IsDo = true
+	lists, err = model.RunModelContext(ctx, db, "proc", map[string]interface{}{"x": "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["x"] != "n" || lists[0]["y0"].(int64) != 100 {
+		t.Errorf("%v", lists)
+	}
+
+	db.Exec(`drop procedure if exists proc_w`)
+	db.Exec(`drop table if exists letters`)
+}
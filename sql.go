@@ -0,0 +1,142 @@
+package godbi
+
+import (
+	"context"
+	"fmt"
+)
+
+// SQL runs a raw statement declared in the model.
+//
+// The placeholders in Statement are bound either positionally, by the names
+// listed in Pars, or by name, as ':name' in Statement. The values are taken
+// from 'extra' first and then from ARGS. Labels, if defined, give the keys
+// and data types of the output rows. ReadOnly tells that the statement
+// does not write, so it is run in a dry run.
+//
+type SQL struct {
+	Action
+	Statement string   `json:"statement" hcl:"statement"`
+	Pars      []string `json:"pars,omitempty" hcl:"pars,optional"`
+	Labels    []*Col   `json:"labels,omitempty" hcl:"labels,optional"`
+	ReadOnly  bool     `json:"readOnly,omitempty" hcl:"readOnly,optional"`
+}
+
+func (self *SQL) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
+}
+
+// RunActionContext runs the statement. If IsDo is true, the statement is
+// executed and the bound values are returned as the only row, otherwise
+// the selected rows are returned.
+//
+func (self *SQL) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	if self.Statement == "" {
+		return nil, fmt.Errorf("statement not defined in action %s", self.ActionName)
+	}
+
+	query, names := namedMarkers(self.Statement)
+	if names == nil {
+		names = self.Pars
+	}
+
+	fieldValues, values, err := bindValues(names, ARGS, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	dbi := &DBI{Executor: db, DBType: t.questionNumber}
+	query = questionMarker(query, t.questionNumber)
+	if self.IsDo {
+		if err = dbi.DoSQLContext(ctx, query, values...); err != nil {
+			return nil, err
+		}
+		return fromFv(fieldValues), nil
+	}
+
+	labels := colLabels(self.Labels)
+	lists := make([]map[string]interface{}, 0)
+	err = dbi.SelectSQLContext(ctx, &lists, query, labels, values...)
+	return lists, err
+}
+
+// bindValues returns the values of 'names', taken from 'extra' first
+// and then from ARGS, as both a map and a slice in order. A name given
+// as nil is bound as NULL.
+//
+func bindValues(names []string, ARGS map[string]interface{}, extra ...map[string]interface{}) (map[string]interface{}, []interface{}, error) {
+	var constraint map[string]interface{}
+	if hasValue(extra) {
+		constraint = extra[0]
+	}
+	fieldValues := make(map[string]interface{})
+	var values []interface{}
+	for _, name := range names {
+		v, ok := constraint[name]
+		if !ok {
+			if v, ok = ARGS[name]; !ok {
+				return nil, nil, newError(ErrValidation, name, "item %s not found in input", name)
+			}
+		}
+		fieldValues[name] = v
+		values = append(values, v)
+	}
+	return fieldValues, values, nil
+}
+
+// colLabels returns the labels of columns, as used in SelectSQL.
+//
+func colLabels(cols []*Col) []interface{} {
+	var labels []interface{}
+	for _, col := range cols {
+		labels = append(labels, col.selectLabel())
+	}
+	return labels
+}
+
+// namedMarkers replaces named placeholders ':name' in query by '?',
+// and returns the new query and the names in order. Quoted strings and
+// Postgres casts '::' are left untouched. The names are nil if
+// no named placeholder is found.
+//
+func namedMarkers(query string) (string, []string) {
+	isName := func(c byte, first bool) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+	}
+
+	var names []string
+	out := make([]byte, 0, len(query))
+	n := len(query)
+	for i := 0; i < n; i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < n && query[j] != c {
+				j++
+			}
+			if j == n {
+				j = n - 1
+			}
+			out = append(out, query[i:j+1]...)
+			i = j
+		case c == ':' && i+1 < n && query[i+1] == ':':
+			out = append(out, "::"...)
+			i++
+		case c == ':' && i+1 < n && isName(query[i+1], true):
+			j := i + 1
+			for j < n && isName(query[j], false) {
+				j++
+			}
+			names = append(names, query[i+1:j])
+			out = append(out, '?')
+			i = j - 1
+		default:
+			out = append(out, c)
+		}
+	}
+
+	if names == nil {
+		return query, nil
+	}
+	return string(out), names
+}
@@ -0,0 +1,92 @@
+package godbi
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamedMarkers(t *testing.T) {
+	query, names := namedMarkers(`SELECT x, y::text, ':z' FROM a WHERE b=:bravo AND c IN (:c1, :c_2)`)
+	if query != `SELECT x, y::text, ':z' FROM a WHERE b=? AND c IN (?, ?)` {
+		t.Errorf("%s", query)
+	}
+	if len(names) != 3 || names[0] != "bravo" || names[1] != "c1" || names[2] != "c_2" {
+		t.Errorf("%#v", names)
+	}
+
+	query, names = namedMarkers(`SELECT x FROM a WHERE b=?`)
+	if query != `SELECT x FROM a WHERE b=?` || names != nil {
+		t.Errorf("%s %#v", query, names)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(portable(`CREATE TABLE m_a (id int auto_increment not null primary key,
+        x varchar(8), y varchar(8), z varchar(8))`))
+
+	str := `{
+    "tableName":"m_a",
+    "pks":["id"],
+    "idAuto":"id",
+    "columns": [
+{"columnName":"x", "label":"x", "typeName":"string", "notnull":true },
+{"columnName":"y", "label":"y", "typeName":"string", "notnull":true },
+{"columnName":"z", "label":"z", "typeName":"string" },
+{"columnName":"id", "label":"id", "typeName":"int", "auto":true }
+    ],
+	"actions": [
+	{
+		"actionName": "sql",
+		"isDo": true,
+		"statement": "INSERT INTO m_a (x, y, z) VALUES (?, ?, ?)",
+		"pars": ["x", "y", "z"]
+	},
+	{
+		"actionName": "topics"
+	}
+]}`
+	model, err := NewModelJson([]byte(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetQuestionNumber(testDBType)
+	model.Actions = append(model.Actions, &SQL{Action: Action{ActionName: "byname"}, Statement: "SELECT id, z FROM m_a WHERE x=:x", Labels: []*Col{{Label: "id", TypeName: "int"}, {Label: "zz", TypeName: "string"}}})
+
+	lists, err := model.RunModelContext(ctx, db, "sql", map[string]interface{}{"x": "a1234567", "y": "b1234567", "z": "temp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["z"] != "temp" {
+		t.Errorf("%v", lists)
+	}
+	if _, err = model.RunModelContext(ctx, db, "sql", map[string]interface{}{"x": "a1234567"}); err == nil {
+		t.Errorf("missing input expected")
+	}
+	// a nil value is bound as NULL
+	if _, err = model.RunModelContext(ctx, db, "sql", map[string]interface{}{"x": "n", "y": "n", "z": nil}); err != nil {
+		t.Fatal(err)
+	}
+	lists = make([]map[string]interface{}, 0)
+	dbi := &DBI{DB: db, KeepNull: true}
+	if err = dbi.SelectContext(ctx, &lists, `SELECT z FROM m_a WHERE x='n'`); err != nil || len(lists) != 1 || lists[0]["z"] != nil {
+		t.Errorf("%v %v", lists, err)
+	}
+
+	lists, err = model.RunModelContext(ctx, db, "byname", map[string]interface{}{"x": "c"}, map[string]interface{}{"x": "a1234567"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0]["id"].(int) != 1 || lists[0]["zz"] != "temp" {
+		t.Errorf("%v", lists)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+}
@@ -0,0 +1,9 @@
+//go:build cgo
+// +build cgo
+
+package godbi
+
+import (
+	// the cgo driver "sqlite3", chosen by GODBI_SQLITE_DRIVER=sqlite3
+	_ "github.com/mattn/go-sqlite3"
+)
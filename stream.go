@@ -0,0 +1,226 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// streamer is an action which passes its output rows, one at a time,
+// to a callback instead of collecting them.
+//
+type streamer interface {
+	StreamActionContext(context.Context, Executor, *Table, map[string]interface{}, func(map[string]interface{}) error, ...map[string]interface{}) error
+}
+
+// StreamModel runs action as RunModel, but passes the output rows to 'fn' one at a time.
+//
+func (self *Model) StreamModel(db Executor, fn func(map[string]interface{}) error, action string, ARGS interface{}, extra ...map[string]interface{}) error {
+	return self.StreamModelContext(context.Background(), db, fn, action, ARGS, extra...)
+}
+
+// StreamModelContext runs action as RunModelContext, but passes the output
+// rows to 'fn' one at a time. Actions like topics read the rows from the
+// database as they are passed, the other actions run first and then pass
+// their outputs. The after hooks of the action run on each row.
+//
+func (self *Model) StreamModelContext(ctx context.Context, db Executor, fn func(map[string]interface{}) error, action string, ARGS interface{}, extra ...map[string]interface{}) error {
+	obj := self.GetAction(action)
+	if obj == nil {
+		return wrapError(newError(ErrConfig, "", "actions or action %s is nil", action), self.TableName, action)
+	}
+	s, ok := obj.(streamer)
+	if !ok {
+		lists, err := self.RunModelContext(ctx, db, action, ARGS, extra...)
+		if err != nil {
+			return err
+		}
+		for _, item := range lists {
+			if err = fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	befores, afters, err := actionHooks(ctx, obj)
+	if err != nil {
+		return wrapError(err, self.TableName, action)
+	}
+	each := fn
+	if afters != nil {
+		// the after hooks run on each row
+		each = func(item map[string]interface{}) error {
+			lists, err := runAfters(ctx, db, &self.Table, action, afters, []map[string]interface{}{item})
+			if err != nil {
+				return err
+			}
+			for _, item := range lists {
+				if err = fn(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	run := func(item map[string]interface{}) error {
+		item, extra, err := runBefores(ctx, db, &self.Table, action, befores, item, extra...)
+		if err != nil {
+			return err
+		}
+		return s.StreamActionContext(ctx, db, &self.Table, item, each, extra...)
+	}
+
+	switch t := ARGS.(type) {
+	case nil:
+		return wrapError(run(nil), self.TableName, action)
+	case map[string]interface{}:
+		return wrapError(run(t), self.TableName, action)
+	case []map[string]interface{}:
+		for _, item := range t {
+			if err := run(item); err != nil {
+				return wrapError(err, self.TableName, action)
+			}
+		}
+		return nil
+	default:
+	}
+	return fmt.Errorf("wrong input data type: %#v", ARGS)
+}
+
+// StreamContext runs action on model as RunContext, but passes the output
+// rows to 'fn' one at a time. The nextpages of a row run lazily, just before
+// the row is passed, so a large table can be exported without holding it in
+// memory. It stops at the first error, from 'fn' or from a nextpage, or when
+// ctx is done.
+//
+// Because the nextpages run while the rows are still being read, they need
+// a pool like *sql.DB, which runs them on other connections. On a *sql.Tx or
+// *sql.Conn, or a *sql.DB limited to one open connection, the rows of an
+// action with nextpages are read first, and then passed one at a time.
+//
+// A slice of args runs as in RunContext, one row after another, while
+// a bulk action, such as bulkinsert, runs first, and its rows are passed.
+//
+func (self *Graph) StreamContext(ctx context.Context, db Executor, fn func(map[string]interface{}) error, model, action string, rest ...interface{}) error {
+	var args map[string]interface{}
+	var extra map[string]interface{}
+	if rest != nil {
+		switch t := rest[0].(type) {
+		case nil:
+		case map[string]interface{}:
+			args = t
+		case []map[string]interface{}, []interface{}:
+			return self.streamSlice(ctx, db, fn, model, action, rest...)
+		default:
+			return fmt.Errorf("Wrong type for args: %#v", rest[0])
+		}
+		if len(rest) == 2 {
+			switch t := rest[1].(type) {
+			case map[string]interface{}: extra = t
+			default:
+				return fmt.Errorf("Wrong type for data: %#v", rest[1])
+			}
+		}
+	}
+
+	if self.argsMap[model] != nil {
+		argsMap := self.argsMap[model].(map[string]interface{})
+		if v, ok := MergeArgs(args, argsMap[action]).(map[string]interface{}); ok {
+			args = v
+		}
+	}
+
+	if self.extraMap[model] != nil {
+		extraAction := self.extraMap[model].(map[string]interface{})
+		if extraAction[action] != nil {
+			extra = MergeExtra(extra, extraAction[action].(map[string]interface{}))
+		}
+	}
+
+	modelObj := self.GetModel(model)
+	if modelObj == nil {
+		return wrapError(newError(ErrConfig, "", "model %s not found in graph", model), model, action)
+	}
+	actionObj := modelObj.GetAction(action)
+	if actionObj == nil {
+		return wrapError(newError(ErrConfig, "", "action %s not found in graph", action), model, action)
+	}
+
+	ctx, err := self.enter(ctx, model, action)
+	if err != nil { return err }
+
+	if args != nil && actionObj.GetIsDo() {
+		args = modelObj.GetTable().RefreshArgs(args).(map[string]interface{})
+	}
+
+	newArgs, newExtra, err := self.preparesContext(ctx, db, model, actionObj.GetPrepares(), args, extra)
+	if err != nil { return err }
+
+	nextpages := actionObj.GetNextpages()
+	each := func(item map[string]interface{}) error {
+		if nextpages != nil {
+			if err := self.nextpagesContext(ctx, db, nextpages, newArgs, newExtra, []map[string]interface{}{item}); err != nil {
+				return err
+			}
+		}
+		return fn(item)
+	}
+
+	if m, ok := modelObj.(*Model); ok && (nextpages == nil || streamable(db)) {
+		return m.StreamModelContext(ctx, db, each, action, newArgs, newExtra)
+	}
+	lists, err := modelObj.RunModelContext(ctx, db, action, newArgs, newExtra)
+	if err != nil { return err }
+	for _, item := range lists {
+		if err = each(item); err != nil { return err }
+	}
+	return nil
+}
+
+// streamSlice streams the rows of each args in the slice rest[0], or, for
+// a bulk action, passes the rows of the run.
+//
+func (self *Graph) streamSlice(ctx context.Context, db Executor, fn func(map[string]interface{}) error, model, action string, rest ...interface{}) error {
+	if self.isBulk(model, action) {
+		lists, err := self.RunContext(ctx, db, model, action, rest...)
+		if err != nil { return err }
+		for _, item := range lists {
+			if err = fn(item); err != nil { return err }
+		}
+		return nil
+	}
+
+	var rows []map[string]interface{}
+	switch t := rest[0].(type) {
+	case []map[string]interface{}:
+		rows = t
+	case []interface{}:
+		for _, arg := range t {
+			if v, ok := arg.(map[string]interface{}); ok {
+				rows = append(rows, v)
+			}
+		}
+	default:
+	}
+	for _, row := range rows {
+		next := append([]interface{}{row}, rest[1:]...)
+		if err := self.StreamContext(ctx, db, fn, model, action, next...); err != nil { return err }
+	}
+	return nil
+}
+
+// streamable tells if the rows of db can be read while the nextpages run
+// on other connections: not on one connection, and not in a pool limited
+// to one connection, where the nextpages would wait for it forever.
+//
+func streamable(db Executor) bool {
+	if singleConn(db) {
+		return false
+	}
+	if pool, ok := db.(*sql.DB); ok {
+		max := pool.Stats().MaxOpenConnections
+		return max == 0 || max > 1
+	}
+	return true
+}
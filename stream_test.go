@@ -0,0 +1,140 @@
+package godbi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, METHODS := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+
+	for _, x := range []string{"a", "b", "c"} {
+		args := map[string]interface{}{"x": x, "y": "y", "z": "z"}
+		graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"insert": map[string]interface{}{"child": "c" + x}}}, nil)
+		if _, err = graph.RunContext(ctx, db, "m_a", METHODS["POST"], args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	graph.Initialize(nil, nil)
+
+	dbi := &DBI{DB: db}
+	var ids []int
+	err = dbi.StreamSQLContext(ctx, func(item map[string]interface{}) error {
+		ids = append(ids, item["id"].(int))
+		return nil
+	}, "SELECT id, x FROM m_a ORDER BY id", []interface{}{[2]string{"id", "int"}, "x"})
+	if err != nil || !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Errorf("%v %v", ids, err)
+	}
+
+	stop := errors.New("stop")
+	ids = nil
+	err = dbi.StreamSQLContext(ctx, func(item map[string]interface{}) error {
+		ids = append(ids, item["id"].(int))
+		if len(ids) == 2 {
+			return stop
+		}
+		return nil
+	}, "SELECT id FROM m_a ORDER BY id", []interface{}{[2]string{"id", "int"}})
+	if err != stop || len(ids) != 2 {
+		t.Errorf("%v %v", ids, err)
+	}
+
+	lists, err := graph.RunContext(ctx, db, "m_a", "topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []map[string]interface{}
+	err = graph.StreamContext(ctx, db, func(item map[string]interface{}) error {
+		if item["m_a_edit"] == nil {
+			t.Errorf("nextpage not run before the row: %#v", item)
+		}
+		streamed = append(streamed, item)
+		return nil
+	}, "m_a", "topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 3 || !reflect.DeepEqual(lists, streamed) {
+		t.Errorf("%#v\n%#v", lists, streamed)
+	}
+
+	// on a transaction, the rows are read before the nextpages run
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed = nil
+	err = graph.StreamContext(ctx, tx, func(item map[string]interface{}) error {
+		streamed = append(streamed, item)
+		return nil
+	}, "m_a", "topics")
+	tx.Rollback()
+	if err != nil || !reflect.DeepEqual(lists, streamed) {
+		t.Errorf("%#v\n%#v %v", lists, streamed, err)
+	}
+
+	// so are they in a pool of one connection, which the nextpages would
+	// otherwise wait for
+	db.SetMaxOpenConns(1)
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	streamed = nil
+	err = graph.StreamContext(tctx, db, func(item map[string]interface{}) error {
+		streamed = append(streamed, item)
+		return nil
+	}, "m_a", "topics")
+	tcancel()
+	db.SetMaxOpenConns(0)
+	if err != nil || !reflect.DeepEqual(lists, streamed) {
+		t.Errorf("%#v\n%#v %v", lists, streamed, err)
+	}
+
+	// a slice of args runs row by row
+	rows := []map[string]interface{}{{"id": 1}, {"id": 3}}
+	lists, err = graph.RunContext(ctx, db, "m_a", "edit", rows)
+	if err != nil || len(lists) != 2 {
+		t.Fatalf("%v %v", lists, err)
+	}
+	streamed = nil
+	err = graph.StreamContext(ctx, db, func(item map[string]interface{}) error {
+		streamed = append(streamed, item)
+		return nil
+	}, "m_a", "edit", rows)
+	if err != nil || !reflect.DeepEqual(lists, streamed) {
+		t.Errorf("%#v\n%#v %v", lists, streamed, err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	n := 0
+	err = graph.StreamContext(cctx, db, func(item map[string]interface{}) error {
+		n++
+		cancel()
+		return nil
+	}, "m_a", "topics", map[string]interface{}{"rowcount": 2})
+	if !errors.Is(err, context.Canceled) || n != 1 {
+		t.Errorf("%d %v", n, err)
+	}
+	cancel()
+
+	model := graph.GetModel("m_b").(*Model)
+	n = 0
+	err = model.StreamModelContext(ctx, db, func(item map[string]interface{}) error {
+		n++
+		return nil
+	}, "topics", nil, map[string]interface{}{"id": 2})
+	if err != nil || n != 1 {
+		t.Errorf("%d %v", n, err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
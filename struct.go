@@ -0,0 +1,328 @@
+package godbi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structFieldsCache caches structFields by struct type.
+//
+var structFieldsCache sync.Map
+
+// structFields returns the index paths of the fields of struct type t, keyed
+// by their lower-cased names: the 'db' tag, or the 'json' tag, or the field
+// name. A tag of "-" skips the field, and the fields of an embedded struct
+// are promoted.
+//
+func structFields(t reflect.Type) map[string][]int {
+	if v, ok := structFieldsCache.Load(t); ok {
+		return v.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("db")
+		if name == "" {
+			name = strings.Split(f.Tag.Get("json"), ",")[0]
+		}
+		if name == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && name == "" {
+			for k, index := range structFields(f.Type) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = []int{i}
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// Decode decodes lists, the output of DBI, Model or Graph, into dest, which
+// is a pointer to a slice of structs or of struct pointers, or a pointer to
+// a struct for the first row. A key is matched to the field of the same
+// 'db' tag, 'json' tag or name, case-insensitively. The nested outputs of
+// nextpages are decoded into struct, slice or map fields the same way.
+//
+func Decode(lists []map[string]interface{}, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decode destination must be a non-nil pointer: %T", dest)
+	}
+	rv = rv.Elem()
+	switch rv.Kind() {
+	case reflect.Slice:
+		return assign(rv, lists)
+	case reflect.Struct:
+		if len(lists) == 0 {
+			return nil
+		}
+		return assign(rv, lists[0])
+	default:
+	}
+	return fmt.Errorf("decode destination must point to a struct or a slice: %T", dest)
+}
+
+// assign sets v to value, converting between the types of the database,
+// such as []byte, int64 and string, and the type of v.
+//
+func assign(v reflect.Value, value interface{}) error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.CanAddr() {
+		if scanner, ok := v.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(value)
+		}
+	}
+
+	rv := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), value)
+	}
+	if v.Kind() == reflect.Interface || rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+		return nil
+	}
+
+	if bs, ok := value.([]byte); ok && v.Kind() != reflect.Slice {
+		value = string(bs)
+		rv = reflect.ValueOf(value)
+	}
+	if str, ok := value.(string); ok {
+		return assignString(v, str)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			v.Set(rv.Convert(v.Type()))
+			return nil
+		case reflect.Bool:
+			if rv.Bool() {
+				v.Set(reflect.ValueOf(1).Convert(v.Type()))
+			} else {
+				v.Set(reflect.Zero(v.Type()))
+			}
+			return nil
+		default:
+		}
+	case reflect.Bool:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v.SetBool(rv.Int() != 0)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v.SetBool(rv.Uint() != 0)
+			return nil
+		default:
+		}
+	case reflect.Struct:
+		if item, ok := value.(map[string]interface{}); ok {
+			fields := structFields(v.Type())
+			for key, x := range item {
+				index, ok := fields[strings.ToLower(key)]
+				if !ok {
+					continue
+				}
+				if err := assign(fieldByIndex(v, index), x); err != nil {
+					return fmt.Errorf("%s: %v", key, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Slice:
+		if rv.Kind() == reflect.Slice {
+			out := reflect.MakeSlice(v.Type(), rv.Len(), rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				if err := assign(out.Index(i), rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			v.Set(out)
+			return nil
+		}
+	case reflect.Map:
+		if rv.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			out := reflect.MakeMap(v.Type())
+			for _, key := range rv.MapKeys() {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := assign(elem, rv.MapIndex(key).Interface()); err != nil {
+					return err
+				}
+				out.SetMapIndex(reflect.ValueOf(fmt.Sprint(key.Interface())).Convert(v.Type().Key()), elem)
+			}
+			v.Set(out)
+			return nil
+		}
+	default:
+	}
+
+	// the last resort, e.g. for a custom type
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v.Addr().Interface())
+}
+
+// timeLayouts are the layouts to parse a time from a string.
+//
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999-07", "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func assignString(v reflect.Value, str string) error {
+	if _, ok := v.Interface().(time.Time); ok {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, str); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("wrong time: %s", str)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("can not assign string to %s", v.Type())
+		}
+		v.SetBytes([]byte(str))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Bool:
+		x, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(x)
+	default:
+		return fmt.Errorf("can not assign string to %s", v.Type())
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of index path, allocating the nil
+// pointers of embedded structs on the way.
+//
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// SelectStruct selects rows into dest, a pointer to a slice of structs, see Decode.
+//
+func (self *DBI) SelectStruct(dest interface{}, query string, args ...interface{}) error {
+	return self.SelectStructContext(context.Background(), dest, query, args...)
+}
+
+// SelectStructContext selects rows into dest, a pointer to a slice of structs, see Decode.
+//
+func (self *DBI) SelectStructContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	lists := make([]map[string]interface{}, 0)
+	if err := self.SelectSQLContext(ctx, &lists, query, nil, args...); err != nil {
+		return err
+	}
+	return Decode(lists, dest)
+}
+
+// GetStruct selects one row into dest, a pointer to a struct. It returns
+// sql.ErrNoRows if there is no row.
+//
+func (self *DBI) GetStruct(dest interface{}, query string, args ...interface{}) error {
+	return self.GetStructContext(context.Background(), dest, query, args...)
+}
+
+// GetStructContext selects one row into dest, a pointer to a struct. It returns
+// sql.ErrNoRows if there is no row.
+//
+func (self *DBI) GetStructContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	lists := make([]map[string]interface{}, 0)
+	if err := self.SelectSQLContext(ctx, &lists, query, nil, args...); err != nil {
+		return err
+	}
+	if len(lists) == 0 {
+		return sql.ErrNoRows
+	}
+	return Decode(lists, dest)
+}
+
+// RunModelStruct runs action as RunModel, and decodes the output into dest.
+//
+func (self *Model) RunModelStruct(db Executor, dest interface{}, action string, ARGS interface{}, extra ...map[string]interface{}) error {
+	return self.RunModelStructContext(context.Background(), db, dest, action, ARGS, extra...)
+}
+
+// RunModelStructContext runs action as RunModelContext, and decodes the output into dest.
+//
+func (self *Model) RunModelStructContext(ctx context.Context, db Executor, dest interface{}, action string, ARGS interface{}, extra ...map[string]interface{}) error {
+	lists, err := self.RunModelContext(ctx, db, action, ARGS, extra...)
+	if err != nil {
+		return err
+	}
+	return Decode(lists, dest)
+}
+
+// RunStructContext runs action on model as RunContext, and decodes the output,
+// including the nested outputs of nextpages, into dest.
+//
+func (self *Graph) RunStructContext(ctx context.Context, db Executor, dest interface{}, model, action string, rest ...interface{}) error {
+	lists, err := self.RunContext(ctx, db, model, action, rest...)
+	if err != nil {
+		return err
+	}
+	return Decode(lists, dest)
+}
@@ -0,0 +1,137 @@
+package godbi
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+type structBase struct {
+	ID int `db:"id"`
+}
+
+type structChild struct {
+	Tid   int64  `json:"tid"`
+	Child string `json:"child"`
+}
+
+type structParent struct {
+	structBase
+	X        string
+	Y        []byte         `db:"y"`
+	Z        *string        `json:"z,omitempty"`
+	N        sql.NullString `db:"n"`
+	Flag     bool           `db:"flag"`
+	Created  time.Time      `db:"created"`
+	Children []*structChild `json:"m_b_topics"`
+	One      structChild    `json:"one"`
+	Counts   map[string]int `json:"counts"`
+	Skipped  string         `db:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	lists := []map[string]interface{}{{
+		"id":         int64(3),
+		"x":          []byte("a"),
+		"y":          "b",
+		"z":          nil,
+		"n":          "n",
+		"flag":       int64(1),
+		"created":    "2021-01-02 03:04:05",
+		"m_b_topics": []map[string]interface{}{{"tid": 1, "child": "john"}, {"tid": "2", "child": "sam"}},
+		"one":        map[string]interface{}{"tid": int64(5)},
+		"counts":     map[string]interface{}{"a": int64(1), "b": "2"},
+		"Skipped":    "s",
+		"unknown":    1,
+	}, {
+		"id": "4",
+		"z":  "zz",
+	}}
+
+	var items []structParent
+	if err := Decode(lists, &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("%#v", items)
+	}
+	p := items[0]
+	if p.ID != 3 || p.X != "a" || string(p.Y) != "b" || p.Z != nil || !p.N.Valid || p.N.String != "n" || !p.Flag ||
+		p.Created.Year() != 2021 || p.Created.Second() != 5 || p.Skipped != "" {
+		t.Errorf("%#v", p)
+	}
+	if len(p.Children) != 2 || p.Children[0].Tid != 1 || p.Children[1].Tid != 2 || p.Children[1].Child != "sam" ||
+		p.One.Tid != 5 || p.Counts["a"] != 1 || p.Counts["b"] != 2 {
+		t.Errorf("%#v %#v %#v", p.Children, p.One, p.Counts)
+	}
+	if items[1].ID != 4 || *items[1].Z != "zz" {
+		t.Errorf("%#v", items[1])
+	}
+
+	var ptrs []*structParent
+	one := new(structParent)
+	if err := Decode(lists, &ptrs); err != nil || len(ptrs) != 2 || ptrs[1].ID != 4 {
+		t.Errorf("%#v %v", ptrs, err)
+	}
+	if err := Decode(lists, one); err != nil || one.ID != 3 {
+		t.Errorf("%#v %v", one, err)
+	}
+	if err := Decode(lists, *one); err == nil {
+		t.Errorf("non-pointer expected to fail")
+	}
+	if err := Decode([]map[string]interface{}{{"id": "x"}}, one); err == nil {
+		t.Errorf("wrong id expected to fail")
+	}
+}
+
+func TestStruct(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, METHODS := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+
+	for _, x := range []string{"a", "b"} {
+		args := map[string]interface{}{"x": x, "y": "y", "z": "z"}
+		graph.Initialize(map[string]interface{}{"m_b": map[string]interface{}{"insert": map[string]interface{}{"child": "c" + x}}}, nil)
+		if _, err = graph.RunContext(ctx, db, "m_a", METHODS["POST"], args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	graph.Initialize(nil, nil)
+
+	dbi := &DBI{DB: db}
+	var items []structParent
+	if err = dbi.SelectStructContext(ctx, &items, "SELECT id, x, y, z FROM m_a ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != 1 || items[1].X != "b" || string(items[1].Y) != "y" || *items[1].Z != "z" {
+		t.Errorf("%#v", items)
+	}
+	one := new(structChild)
+	if err = dbi.GetStructContext(ctx, one, "SELECT tid, child FROM m_b WHERE id=?", 2); err != nil || one.Tid != 2 || one.Child != "cb" {
+		t.Errorf("%#v %v", one, err)
+	}
+	if err = dbi.GetStructContext(ctx, one, "SELECT tid, child FROM m_b WHERE id=?", 3); err != sql.ErrNoRows {
+		t.Errorf("%v", err)
+	}
+
+	parent := new(structParent)
+	if err = graph.RunStructContext(ctx, db, parent, "m_a", "edit", map[string]interface{}{"id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if parent.ID != 2 || parent.X != "b" || len(parent.Children) != 1 || parent.Children[0].Child != "cb" {
+		t.Errorf("%#v", parent)
+	}
+
+	model := graph.GetModel("m_a").(*Model)
+	items = nil
+	if err = model.RunModelStructContext(ctx, db, &items, "topics", map[string]interface{}{}); err != nil || len(items) != 2 {
+		t.Errorf("%#v %v", items, err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}
@@ -2,9 +2,9 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,6 +19,8 @@ const (
 	Postgres
 	TSMillisecond
 	TSMicrosecond
+	SQLServer
+	Oracle
 )
 
 type Col struct {
@@ -27,6 +29,26 @@ type Col struct {
 	Label string       `json:"label" hcl:"label"`
 	Notnull bool       `json:"notnull" hcl:"notnull"`
 	Auto bool          `json:"auto" hcl:"auto"`
+	KeepNull bool      `json:"keepNull,omitempty" hcl:"keepNull,optional"`
+	RawBytes bool      `json:"rawBytes,omitempty" hcl:"rawBytes,optional"`
+	NoTrim bool        `json:"noTrim,omitempty" hcl:"noTrim,optional"`
+	// Location: the time zone of a date or timestamp column, e.g. "America/New_York"
+	Location string    `json:"location,omitempty" hcl:"location,optional"`
+	// constraints of the input value, the length for a string
+	Min *float64       `json:"min,omitempty" hcl:"min,optional"`
+	Max *float64       `json:"max,omitempty" hcl:"max,optional"`
+	Pattern string     `json:"pattern,omitempty" hcl:"pattern,optional"`
+	Enum []string      `json:"enum,omitempty" hcl:"enum,optional"`
+}
+
+// selectLabel returns the label of the column in SelectSQL: a Label if
+// any pickup option is set, otherwise the key name and data type.
+//
+func (self *Col) selectLabel() interface{} {
+	if self.KeepNull || self.RawBytes || self.NoTrim || self.Location != "" {
+		return Label{Name: self.Label, Type: self.TypeName, KeepNull: self.KeepNull, RawBytes: self.RawBytes, NoTrim: self.NoTrim, Location: columnLocation(self.Location)}
+	}
+	return [2]string{self.Label, self.TypeName}
 }
 
 type Fk struct {
@@ -43,6 +65,9 @@ type Table struct {
 	Fks       []*Fk    `json:"fks,omitempty" hcl:"fks,optional"`
 	Uniques   []string `json:"uniques,omitempty" hcl:"uniques,optional"`
 	questionNumber DBType
+	// the live name of the unique index, and if it is of a constraint, by introspection
+	uniqueIndex      string
+	uniqueConstraint bool
 }
 
 func (self *Table) GetTableName() string {
@@ -115,7 +140,7 @@ func (self *Table) getFv(ARGS map[string]interface{}) map[string]interface{} {
 			case []map[string]interface{}, map[string]interface{}:
 			case bool:
 				switch self.questionNumber {
-				case SQLite, TSMillisecond, TSMicrosecond:
+				case SQLite, SQLServer, Oracle, TSMillisecond, TSMicrosecond:
 					if t {
 						fieldValues[f] = 1
 					} else {
@@ -136,25 +161,6 @@ func (self *Table) getFv(ARGS map[string]interface{}) map[string]interface{} {
     return fieldValues
 }
 
-func (self *Table) checkNull(ARGS map[string]interface{}, extra ...map[string]interface{}) error {
-	for _, col := range self.Columns {
-		if col.Notnull == false || col.Auto == true {
-			continue
-		} // the column is ok with null
-		err := fmt.Errorf("item %s not found in input", col.ColumnName)
-		if _, ok := ARGS[col.ColumnName]; !ok {
-			if hasValue(extra) && hasValue(extra[0]) {
-				if _, ok = extra[0][col.ColumnName]; !ok {
-					return err
-				}
-			} else {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 func (self *Table) insertCols() map[string]string {
 	cols := make(map[string]string)
 	for _, col := range self.Columns {
@@ -164,7 +170,7 @@ func (self *Table) insertCols() map[string]string {
 	return cols
 }
 
-func (self *Table) insertHashContext(ctx context.Context, db *sql.DB, args map[string]interface{}) (int64, error) {
+func (self *Table) insertHashContext(ctx context.Context, db Executor, args map[string]interface{}) (int64, error) {
 	var fields []string
 	var values []interface{}
 	if self.IdAuto != "" && self.questionNumber == TSMillisecond {
@@ -181,19 +187,34 @@ func (self *Table) insertHashContext(ctx context.Context, db *sql.DB, args map[s
 		}
 	}
 
-	sql := "INSERT INTO " + self.TableName + " (" + strings.Join(fields, ", ") + ") VALUES (" + strings.Join(strings.Split(strings.Repeat("?", len(fields)), ""), ",") + ")"
+	sql := self.insertStatement(fields)
+	dialect := self.questionNumber
+	if dialect == SQLite && self.IdAuto != "" {
+		returning, err := sqliteReturning(ctx, db)
+		if err != nil {
+			return 0, err
+		}
+		if !returning { // the last insert id of SQLite before 3.35
+			sql = strings.TrimSuffix(sql, " RETURNING "+self.IdAuto)
+			dialect = SQLDefault
+		}
+	}
 
-	dbi := &DBI{DB: db}
+	dbi := &DBI{Executor: db}
 	var err error
-	switch self.questionNumber {
-	case Postgres:
-		sql = questionMarkerNumber(sql)
+	switch dialect {
+	case Postgres, SQLite, SQLServer:
 		if self.IdAuto != "" {
-			sql += " RETURNING " + self.IdAuto
 			err = dbi.InsertSerialContext(ctx, sql, values...)
 		} else {
 			err = dbi.DoSQLContext(ctx, sql, values...)
 		}
+	case Oracle:
+		if self.IdAuto != "" {
+			err = dbi.InsertReturningContext(ctx, sql, values...)
+		} else {
+			err = dbi.DoSQLContext(ctx, sql, values...)
+		}
 	case SQLRaw, TSMillisecond, TSMicrosecond:
 		err = dbi.DoSQLContext(ctx, sql, values...)
 	default:
@@ -205,13 +226,39 @@ func (self *Table) insertHashContext(ctx context.Context, db *sql.DB, args map[s
 	return dbi.LastID, nil
 }
 
-func (self *Table) updateHashNullsContext(ctx context.Context, db *sql.DB, args map[string]interface{}, ids []interface{}, empties []string, extra ...map[string]interface{}) error {
+// insertStatement returns the INSERT statement of fields in the dialect,
+// which also returns IdAuto by RETURNING in Postgres and SQLite, by
+// OUTPUT INSERTED in SQL Server, and by RETURNING INTO in Oracle.
+//
+func (self *Table) insertStatement(fields []string) string {
+	marks := strings.Join(strings.Split(strings.Repeat("?", len(fields)), ""), ",")
+	table := quoteName(self.TableName, self.questionNumber)
+	names := strings.Join(quoteNames(fields, self.questionNumber), ", ")
+	sql := "INSERT INTO " + table + " (" + names + ") VALUES (" + marks + ")"
+	if self.IdAuto == "" {
+		return questionMarker(sql, self.questionNumber)
+	}
+
+	idAuto := quoteName(self.IdAuto, self.questionNumber)
+	switch self.questionNumber {
+	case Postgres, SQLite:
+		sql += " RETURNING " + idAuto
+	case SQLServer:
+		sql = "INSERT INTO " + table + " (" + names + ") OUTPUT INSERTED." + idAuto + " VALUES (" + marks + ")"
+	case Oracle:
+		sql += " RETURNING " + idAuto + " INTO ?"
+	default:
+	}
+	return questionMarker(sql, self.questionNumber)
+}
+
+func (self *Table) updateHashNullsContext(ctx context.Context, db Executor, args map[string]interface{}, ids []interface{}, empties []string, extra ...map[string]interface{}) error {
 	if !hasValue(args) {
-		return fmt.Errorf("no input data")
+		return newError(ErrValidation, "", "no input data")
 	}
 	for _, k := range self.Pks {
 		if grep(empties, k) {
-			return fmt.Errorf("PK can't be NULL")
+			return newError(ErrValidation, k, "PK can't be NULL")
 		}
 	}
 
@@ -220,19 +267,22 @@ func (self *Table) updateHashNullsContext(ctx context.Context, db *sql.DB, args
 	var values []interface{}
 	for k, v := range args {
 		fields = append(fields, k)
-		field0 = append(field0, k+"=?")
+		field0 = append(field0, quoteName(k, self.questionNumber)+"=?")
 		values = append(values, v)
 	}
 
-	sql := "UPDATE " + self.TableName + " SET " + strings.Join(field0, ", ")
+	sql := "UPDATE " + quoteName(self.TableName, self.questionNumber) + " SET " + strings.Join(field0, ", ")
 	for _, v := range empties {
 		if _, ok := args[v]; ok {
 			continue
 		}
-		sql += ", " + v + "=NULL"
+		sql += ", " + quoteName(v, self.questionNumber) + "=NULL"
 	}
 
-	where, extraValues := self.singleCondition(ids, "", extra...)
+	where, extraValues, err := self.singleCondition(ids, "", extra...)
+	if err != nil {
+		return err
+	}
 	if where != "" {
 		sql += "\nWHERE " + where
 		for _, v := range extraValues {
@@ -240,14 +290,19 @@ func (self *Table) updateHashNullsContext(ctx context.Context, db *sql.DB, args
 		}
 	}
 
-	dbi := &DBI{DB: db}
-	if self.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
-	return dbi.DoSQLContext(ctx, sql, values...)
+	sql = questionMarker(sql, self.questionNumber)
+	_, err = db.ExecContext(ctx, sql, values...)
+	return err
 }
 
-func (self *Table) insupdTableContext(ctx context.Context, db *sql.DB, args map[string]interface{}) (int64, error) {
+// insupdTableContext updates the row of the same unique key as args, or
+// inserts args if not found, and returns the auto id. It runs as one native
+// upsert in SQLite 3.24 or newer, 3.35 with IdAuto, Postgres and MySQL,
+// unless selectFirst is true. Otherwise it selects the row first.
+//
+func (self *Table) insupdTableContext(ctx context.Context, db Executor, args map[string]interface{}, selectFirst bool) (int64, error) {
 	changed := int64(0)
-	s := "SELECT " + strings.Join(self.Pks, ", ") + " FROM " + self.TableName + "\nWHERE "
+	s := "SELECT " + strings.Join(quoteNames(self.Pks, self.questionNumber), ", ") + " FROM " + quoteName(self.TableName, self.questionNumber) + "\nWHERE "
 	var v []interface{}
 	if self.Uniques == nil {
 		return changed, fmt.Errorf("unique key not defined")
@@ -256,23 +311,45 @@ func (self *Table) insupdTableContext(ctx context.Context, db *sql.DB, args map[
 		if i > 0 {
 			s += " AND "
 		}
-		s += val + "=?"
+		s += quoteName(val, self.questionNumber) + "=?"
 		if x, ok := args[val]; ok {
 			v = append(v, x)
 		} else {
-			return changed, fmt.Errorf("input of unique key %s not found", val)
+			return changed, newError(ErrValidation, val, "input of unique key %s not found", val)
+		}
+	}
+
+	upsert := !selectFirst
+	switch self.questionNumber {
+	case SQLite:
+		if upsert {
+			minor := 24 // ON CONFLICT DO UPDATE
+			if self.IdAuto != "" {
+				minor = 35 // RETURNING
+			}
+			since, err := sqliteSince(ctx, db, minor)
+			if err != nil {
+				return changed, err
+			}
+			upsert = since
 		}
+	case Postgres, MySQL:
+	default:
+		upsert = false
+	}
+	if upsert {
+		return self.upsertContext(ctx, db, args)
 	}
 
 	lists := make([]map[string]interface{}, 0)
-	dbi := &DBI{DB: db}
-	if self.questionNumber == Postgres { s = questionMarkerNumber(s) }
+	dbi := &DBI{Executor: db}
+	s = questionMarker(s, self.questionNumber)
 	err := dbi.SelectContext(ctx, &lists, s, v...)
 	if err != nil {
 		return changed, err
 	}
 	if len(lists) > 1 {
-		return changed, fmt.Errorf("multiple records found for unique key")
+		return changed, newError(ErrConflict, strings.Join(self.Uniques, ", "), "multiple records found for unique key")
 	}
 
 	if len(lists) == 1 {
@@ -283,8 +360,8 @@ func (self *Table) insupdTableContext(ctx context.Context, db *sql.DB, args map[
 		err = self.updateHashNullsContext(ctx, db, args, ids, nil)
 		if err == nil && self.IdAuto != "" {
 			res := make(map[string]interface{})
-			sql := "SELECT " + self.IdAuto + " FROM " + self.TableName + "\nWHERE " + strings.Join(self.Pks, "=? AND ") + "=?"
-			if self.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
+			sql := "SELECT " + quoteName(self.IdAuto, self.questionNumber) + " FROM " + quoteName(self.TableName, self.questionNumber) + "\nWHERE " + strings.Join(quoteNames(self.Pks, self.questionNumber), "=? AND ") + "=?"
+			sql = questionMarker(sql, self.questionNumber)
 			if err = dbi.GetSQLContext(ctx, res, sql, nil, ids...); err == nil {
 				changed = res[self.IdAuto].(int64)
 			}
@@ -296,15 +373,93 @@ func (self *Table) insupdTableContext(ctx context.Context, db *sql.DB, args map[
 	return changed, err
 }
 
-func (self *Table) totalHashContext(ctx context.Context, db *sql.DB, v interface{}, extra ...map[string]interface{}) error {
-	sql := "SELECT COUNT(*) FROM " + self.TableName
+// upsertStatement returns the native upsert statement of args and its
+// values: INSERT ... ON CONFLICT (uniques) DO UPDATE ... RETURNING in
+// Postgres and SQLite, and INSERT ... ON DUPLICATE KEY UPDATE in MySQL,
+// where LAST_INSERT_ID(id) makes the id of an updated row the last insert id.
+//
+func (self *Table) upsertStatement(args map[string]interface{}) (string, []interface{}) {
+	var keys []string
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dbType := self.questionNumber
+	var fields, sets []string
+	var values []interface{}
+	idAuto := quoteName(self.IdAuto, dbType)
+	if dbType == MySQL && idAuto != "" {
+		sets = append(sets, idAuto+"=LAST_INSERT_ID("+idAuto+")")
+	}
+	for _, k := range keys {
+		v := args[k]
+		name := quoteName(k, dbType)
+		if v == nil {
+			sets = append(sets, name+"=NULL")
+			continue
+		}
+		fields = append(fields, name)
+		values = append(values, v)
+		if grep(self.Uniques, k) {
+			continue
+		}
+		if dbType == MySQL {
+			sets = append(sets, name+"=VALUES("+name+")")
+		} else {
+			sets = append(sets, name+"=excluded."+name)
+		}
+	}
+	first := quoteName(self.Uniques[0], dbType)
+	if sets == nil && dbType == MySQL {
+		sets = append(sets, first+"=VALUES("+first+")")
+	} else if sets == nil {
+		sets = append(sets, first+"=excluded."+first)
+	}
+
+	sql := "INSERT INTO " + quoteName(self.TableName, dbType) + " (" + strings.Join(fields, ", ") + ") VALUES (" + strings.Join(strings.Split(strings.Repeat("?", len(fields)), ""), ",") + ")"
+	if dbType == MySQL {
+		sql += "\nON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	} else {
+		sql += "\nON CONFLICT (" + strings.Join(quoteNames(self.Uniques, dbType), ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+		if idAuto != "" {
+			sql += " RETURNING " + idAuto
+		}
+	}
+	return questionMarker(sql, self.questionNumber), values
+}
+
+// upsertContext inserts args, or updates the row of the same unique key,
+// in one statement, so there is no race between finding the row and
+// writing it. The unique key must have a unique index.
+//
+func (self *Table) upsertContext(ctx context.Context, db Executor, args map[string]interface{}) (int64, error) {
+	sql, values := self.upsertStatement(args)
+	dbi := &DBI{Executor: db}
+	var err error
+	switch {
+	case self.IdAuto == "":
+		err = dbi.DoSQLContext(ctx, sql, values...)
+	case self.questionNumber == MySQL:
+		err = dbi.InsertIDContext(ctx, sql, values...)
+	default:
+		err = dbi.InsertSerialContext(ctx, sql, values...)
+	}
+	return dbi.LastID, err
+}
+
+func (self *Table) totalHashContext(ctx context.Context, db Executor, v interface{}, extra ...map[string]interface{}) error {
+	sql := "SELECT COUNT(*) FROM " + quoteName(self.TableName, self.questionNumber)
 
 	if hasValue(extra) {
-		where, values := selectCondition(extra[0], "")
+		where, values, err := selectCondition(extra[0], "", self.questionNumber, self.Columns)
+		if err != nil {
+			return err
+		}
 		if where != "" {
 			sql += "\nWHERE " + where
 		}
-		if self.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
+		sql = questionMarker(sql, self.questionNumber)
 		return db.QueryRowContext(ctx, sql, values...).Scan(v)
 	}
 
@@ -318,13 +473,14 @@ func (self *Table) getIdVal(ARGS map[string]interface{}, extra ...map[string]int
 	return properValues(self.Pks, ARGS, nil)
 }
 
-func (self *Table) singleCondition(ids []interface{}, table string, extra ...map[string]interface{}) (string, []interface{}) {
+func (self *Table) singleCondition(ids []interface{}, table string, extra ...map[string]interface{}) (string, []interface{}, error) {
 	keys := self.Pks
 	sql := ""
 	var extraValues []interface{}
 
 	for i, item := range keys {
 		val := ids[i]
+		item = quoteName(item, self.questionNumber)
 		if i == 0 {
 			sql = "("
 		} else {
@@ -345,14 +501,17 @@ func (self *Table) singleCondition(ids []interface{}, table string, extra ...map
 	sql += ")"
 
 	if hasValue(extra) && hasValue(extra[0]) {
-		s, arr := selectCondition(extra[0], table)
-		sql += " AND " + s
-		for _, u := range arr {
-			extraValues = append(extraValues, u)
+		s, arr, err := selectCondition(extra[0], table, self.questionNumber, self.Columns)
+		if err != nil {
+			return "", nil, err
+		}
+		if s != "" {
+			sql += " AND " + s
+			extraValues = append(extraValues, arr...)
 		}
 	}
 
-	return sql, extraValues
+	return sql, extraValues, nil
 }
 
 func properValue(u string, ARGS map[string]interface{}, extra map[string]interface{}) interface{} {
@@ -392,18 +551,42 @@ func properValuesHash(vs []string, ARGS map[string]interface{}, extra map[string
 	return hash
 }
 
-func selectCondition(extra map[string]interface{}, table string) (string, []interface{}) {
-	sql := ""
-	var values []interface{}
-	i := 0
+// selectCondition returns the WHERE statement and its values from 'extra'.
+// A key is a column name, prefixed with 'table' if not yet, and its value:
+// 1) a slice gives an IN constraint,
+// 2) a string of key ending with '_gsql' is a raw SQL statement,
+// 3) a map gives operators on the column, see filterOperators,
+// 4) any other value gives an EQUAL constraint.
+// The key '$or' takes a slice of maps, each compiled by selectCondition,
+// which are joined by OR. The AND relation is assumed among multiple keys.
+// A column not in 'cols' is rejected, unless 'cols' is empty.
+//
+func selectCondition(extra map[string]interface{}, table string, dbType DBType, cols []*Col) (string, []interface{}, error) {
+	var keys []string
+	for field := range extra {
+		keys = append(keys, field)
+	}
+	sort.Strings(keys)
 
-	for field, valueInterface := range extra {
-		if i > 0 {
-			sql += " AND "
+	var terms []string
+	var values []interface{}
+	for _, field := range keys {
+		valueInterface := extra[field]
+		if field == "$or" {
+			s, vs, err := orCondition(valueInterface, table, dbType, cols)
+			if err != nil {
+				return "", nil, err
+			}
+			if s != "" {
+				terms = append(terms, "("+s+")")
+				values = append(values, vs...)
+			}
+			continue
 		}
-		i++
-		sql += "("
 
+		if !isRawSQL(field, valueInterface) && !knownColumn(field, cols) {
+			return "", nil, newError(ErrValidation, field, "unknown column %s", field)
+		}
 		if table != "" {
 			match, err := regexp.MatchString("\\.", field)
 			if err == nil && !match {
@@ -411,40 +594,32 @@ func selectCondition(extra map[string]interface{}, table string) (string, []inte
 			}
 		}
 		switch value := valueInterface.(type) {
-		case []int:
-			n := len(value)
-			sql += field + " IN (" + strings.Join(strings.Split(strings.Repeat("?", n), ""), ",") + ")"
-			for _, v := range value {
-				values = append(values, v)
-			}
-		case []int64:
-			n := len(value)
-			sql += field + " IN (" + strings.Join(strings.Split(strings.Repeat("?", n), ""), ",") + ")"
-			for _, v := range value {
-				values = append(values, v)
-			}
-		case []string:
-			n := len(value)
-			sql += field + " IN (" + strings.Join(strings.Split(strings.Repeat("?", n), ""), ",") + ")"
-			for _, v := range value {
-				values = append(values, v)
+		case map[string]interface{}:
+			s, vs, err := filterOperators(field, value, dbType)
+			if err != nil {
+				return "", nil, err
 			}
+			terms = append(terms, "("+s+")")
+			values = append(values, vs...)
 		case string:
-			n := len(field)
-			if n >= 5 && field[(n-5):] == "_gsql" {
-				sql += value
+			if isRawSQL(field, value) {
+				terms = append(terms, "("+value+")")
 			} else {
-				sql += field + " =?"
+				terms = append(terms, "("+quoteName(field, dbType)+" =?)")
 				values = append(values, value)
 			}
 		default:
-			sql += field + " =?"
-			values = append(values, value)
+			if vs, ok := toSlice(value); ok {
+				terms = append(terms, "("+quoteName(field, dbType)+" IN ("+strings.Join(strings.Split(strings.Repeat("?", len(vs)), ""), ",")+"))")
+				values = append(values, vs...)
+			} else {
+				terms = append(terms, "("+quoteName(field, dbType)+" =?)")
+				values = append(values, value)
+			}
 		}
-		sql += ")"
 	}
 
-	return sql, values
+	return strings.Join(terms, " AND "), values, nil
 }
 
 func (self *Table) filterPars(ARGS map[string]interface{}, fieldsName string, joins []*Joint) (string, []interface{}, string) {
@@ -458,17 +633,17 @@ func (self *Table) filterPars(ARGS map[string]interface{}, fieldsName string, jo
 	for _, col := range self.Columns {
 		if fields==nil || grep (fields, col.ColumnName) {
 			keys = append(keys, col.ColumnName)
-			labels = append(labels, [2]string{col.Label, col.TypeName})
+			labels = append(labels, col.selectLabel())
 		}
 	}
-	sql := strings.Join(keys, ", ")
+	sql := strings.Join(quoteNames(keys, self.questionNumber), ", ")
 
 	var table string
 	if hasValue(joins) {
 		sql = "SELECT " + sql + "\nFROM " + joinString(joins)
 		table = joins[0].getAlias()
 	} else {
-		sql = "SELECT " + sql + "\nFROM " + self.TableName
+		sql = "SELECT " + sql + "\nFROM " + quoteName(self.TableName, self.questionNumber)
 	}
 
 	return sql, labels, table
@@ -1,7 +1,10 @@
 package godbi
 
 import (
+	"context"
 	"encoding/json"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -44,3 +47,141 @@ func TestTable(t *testing.T) {
 		t.Errorf("%v", table)
 	}
 }
+
+func TestTableSQLite(t *testing.T) {
+	db := opensqlite(t, sqliteDSN(filepath.Join(t.TempDir(), "sqlite.db")))
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`CREATE TABLE m_a (id INTEGER PRIMARY KEY AUTOINCREMENT, x VARCHAR(8), y VARCHAR(8), z VARCHAR(8), UNIQUE (x, y))`)
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Uniques: []string{"x", "y"}, Columns: []*Col{
+		{ColumnName: "x", TypeName: "string", Label: "x"},
+		{ColumnName: "y", TypeName: "string", Label: "y"},
+		{ColumnName: "z", TypeName: "string", Label: "z"},
+		{ColumnName: "id", TypeName: "int", Label: "id", Auto: true}}}
+	table.SetQuestionNumber(SQLite)
+
+	// the id comes from RETURNING
+	id, err := table.insertHashContext(ctx, db, map[string]interface{}{"x": "a", "y": "b", "z": "c"})
+	if err != nil || id != 1 {
+		t.Fatalf("%d %v", id, err)
+	}
+
+	// before SQLite 3.35, the id comes from the last insert id
+	if major, minor := parseVersion("3.34.1"); major != 3 || minor != 34 {
+		t.Errorf("%d.%d", major, minor)
+	}
+	if _, err = sqliteReturning(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	sqliteVersion.Lock()
+	major, minor := sqliteVersion.major, sqliteVersion.minor
+	sqliteVersion.major, sqliteVersion.minor = 3, 34
+	sqliteVersion.Unlock()
+	id, err = table.insertHashContext(ctx, db, map[string]interface{}{"x": "b", "y": "b", "z": "c"})
+	sqliteVersion.Lock()
+	sqliteVersion.major, sqliteVersion.minor = major, minor
+	sqliteVersion.Unlock()
+	if err != nil || id != 2 {
+		t.Fatalf("%d %v", id, err)
+	}
+
+	// concurrent insupd on the same unique key write one row
+	var wg sync.WaitGroup
+	ids := make([]int64, 8)
+	errs := make([]error, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = table.insupdTableContext(ctx, db, map[string]interface{}{"x": "d", "y": "e", "z": "f"}, true)
+		}(i)
+	}
+	wg.Wait()
+	for i := range ids {
+		if errs[i] != nil || ids[i] != ids[0] {
+			t.Errorf("%d %d %v", i, ids[i], errs[i])
+		}
+	}
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM m_a WHERE x='d'`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("%d %v", n, err)
+	}
+
+	// insupd on an existing row returns its id and updates it
+	if id, err = table.insupdTableContext(ctx, db, map[string]interface{}{"x": "a", "y": "b", "z": "zz"}, true); err != nil || id != 1 {
+		t.Errorf("%d %v", id, err)
+	}
+	var z string
+	if err = db.QueryRow(`SELECT z FROM m_a WHERE id=1`).Scan(&z); err != nil || z != "zz" {
+		t.Errorf("%s %v", z, err)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	table := &Table{TableName: "m_a", Pks: []string{"id"}, IdAuto: "id", Uniques: []string{"x", "y"}}
+	args := map[string]interface{}{"x": "a", "y": "b", "z": "c", "w": nil}
+	for dbType, upsert := range map[DBType]string{
+		MySQL: "INSERT INTO m_a (x, y, z) VALUES (?,?,?)\nON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id), w=NULL, z=VALUES(z)",
+		SQLite: "INSERT INTO m_a (x, y, z) VALUES (?,?,?)\nON CONFLICT (x, y) DO UPDATE SET w=NULL, z=excluded.z RETURNING id",
+		Postgres: "INSERT INTO m_a (x, y, z) VALUES ($1,$2,$3)\nON CONFLICT (x, y) DO UPDATE SET w=NULL, z=excluded.z RETURNING id",
+	} {
+		table.SetQuestionNumber(dbType)
+		str, values := table.upsertStatement(args)
+		if str != upsert || len(values) != 3 || values[2] != "c" {
+			t.Errorf("%d: %s %v", dbType, str, values)
+		}
+	}
+
+	table.IdAuto = ""
+	table.SetQuestionNumber(MySQL)
+	if str, _ := table.upsertStatement(map[string]interface{}{"x": "a", "y": "b"}); str != "INSERT INTO m_a (x, y) VALUES (?,?)\nON DUPLICATE KEY UPDATE x=VALUES(x)" {
+		t.Errorf("%s", str)
+	}
+	table.SetQuestionNumber(Postgres)
+	if str, _ := table.upsertStatement(map[string]interface{}{"x": "a", "y": "b"}); str != "INSERT INTO m_a (x, y) VALUES ($1,$2)\nON CONFLICT (x, y) DO UPDATE SET x=excluded.x" {
+		t.Errorf("%s", str)
+	}
+
+	// with selectFirst, and in the other dialects, select, then update or
+	// insert, which works without a unique index
+	db := opensqlite(t, filepath.Join(t.TempDir(), "sqlite.db"))
+	defer db.Close()
+	ctx := context.Background()
+	db.Exec(`CREATE TABLE m_a (id INTEGER PRIMARY KEY, x VARCHAR(8), y VARCHAR(8), z VARCHAR(8))`)
+	table.IdAuto = "id"
+	for _, dbType := range []DBType{SQLDefault, SQLite} {
+		table.SetQuestionNumber(dbType)
+		for i, z := range []string{"c", "d"} {
+			id, err := table.insupdTableContext(ctx, db, map[string]interface{}{"x": "a", "y": "b", "z": z}, true)
+			if err != nil || id != 1 {
+				t.Errorf("%d %d: %d %v", dbType, i, id, err)
+			}
+		}
+	}
+	table.Columns = []*Col{{ColumnName: "x", TypeName: "string", Label: "x"}, {ColumnName: "y", TypeName: "string", Label: "y"},
+		{ColumnName: "z", TypeName: "string", Label: "z"}, {ColumnName: "id", TypeName: "int", Label: "id", Auto: true}}
+	insupd := &Insupd{Action: Action{IsDo: true}, SelectFirst: true}
+	lists, err := insupd.RunActionContext(ctx, db, table, map[string]interface{}{"x": "a", "y": "b", "z": "e"})
+	if err != nil || len(lists) != 1 || lists[0]["id"] != int64(1) {
+		t.Errorf("%v %v", lists, err)
+	}
+	// by default, the native upsert, which needs the unique index
+	insupd.SelectFirst = false
+	if _, err = insupd.RunActionContext(ctx, db, table, map[string]interface{}{"x": "a", "y": "b", "z": "f"}); err == nil {
+		t.Errorf("missing unique index expected")
+	}
+	if _, err = db.Exec(`CREATE UNIQUE INDEX m_a_xy ON m_a (x, y)`); err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range []string{"a", "g"} {
+		lists, err = insupd.RunActionContext(ctx, db, table, map[string]interface{}{"x": x, "y": "b", "z": "f"})
+		if err != nil || len(lists) != 1 || lists[0]["id"] != int64(i+1) {
+			t.Errorf("%v %v", lists, err)
+		}
+	}
+	var z string
+	if err = db.QueryRow(`SELECT z FROM m_a WHERE id=1`).Scan(&z); err != nil || z != "f" {
+		t.Errorf("%s %v", z, err)
+	}
+}
@@ -2,7 +2,6 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
 	"math"
 	"regexp"
 	"strconv"
@@ -21,6 +20,12 @@ type Topics struct {
 	PAGENO      string `json:"pageno,omitempty" hcl:"pageno,optional"`
 	SORTBY      string `json:"sortby,omitempty" hcl:"sortby,optional"`
 	SORTREVERSE string `json:"sortreverse,omitempty" hcl:"sortreverse,optional"`
+
+	// Keyset: page by cursors instead of LIMIT and OFFSET
+	Keyset      bool   `json:"keyset,omitempty" hcl:"keyset,optional"`
+	CURSOR      string `json:"cursor,omitempty" hcl:"cursor,optional"`
+	NEXTCURSOR  string `json:"nextcursor,omitempty" hcl:"nextcursor,optional"`
+	PREVCURSOR  string `json:"prevcursor,omitempty" hcl:"prevcursor,optional"`
 }
 
 func (self *Topics) setDefaultElementNames() []string {
@@ -45,7 +50,16 @@ func (self *Topics) setDefaultElementNames() []string {
 	if self.MAXPAGENO == "" {
 		self.MAXPAGENO = "maxpageno"
 	}
-	return []string{self.FIELDS, self.SORTBY, self.SORTREVERSE, self.ROWCOUNT, self.PAGENO, self.TOTALNO, self.MAXPAGENO}
+	if self.CURSOR == "" {
+		self.CURSOR = "cursor"
+	}
+	if self.NEXTCURSOR == "" {
+		self.NEXTCURSOR = "nextcursor"
+	}
+	if self.PREVCURSOR == "" {
+		self.PREVCURSOR = "prevcursor"
+	}
+	return []string{self.FIELDS, self.SORTBY, self.SORTREVERSE, self.ROWCOUNT, self.PAGENO, self.TOTALNO, self.MAXPAGENO, self.CURSOR, self.NEXTCURSOR, self.PREVCURSOR}
 }
 
 // orderString outputs the ORDER BY string using information in args
@@ -74,7 +88,10 @@ func (self *Topics) orderString(t *Table, ARGS map[string]interface{}) string {
 		column = strings.Join(t.Pks, ", ")
 	}
 
-	order := "ORDER BY " + column
+	if matched, err := regexp.MatchString("[;'\"]", column); err != nil || matched {
+		return ""
+	}
+	order := "ORDER BY " + quoteList(column, t.questionNumber)
 	if _, ok := ARGS[nameSortreverse]; ok {
 		order += " DESC"
 	}
@@ -99,17 +116,12 @@ func (self *Topics) orderString(t *Table, ARGS map[string]interface{}) string {
 		} else {
 			ARGS[namePageno] = 1
 		}
-		order += " LIMIT " + strconv.Itoa(rowcount) + " OFFSET " + strconv.Itoa((pageno-1)*rowcount)
-	}
-
-	matched, err := regexp.MatchString("[;'\"]", order)
-	if err != nil || matched {
-		return ""
+		order += " " + limitOffset(t.questionNumber, rowcount, (pageno-1)*rowcount)
 	}
 	return order
 }
 
-func (self *Topics) pagination(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) error {
+func (self *Topics) pagination(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) error {
 	nameTotalno := self.TOTALNO
 	nameRowcount := self.ROWCOUNT
 	namePageno := self.PAGENO
@@ -158,42 +170,72 @@ func (self *Topics) pagination(ctx context.Context, db *sql.DB, t *Table, ARGS m
 	return nil
 }
 
-func (self *Topics) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Topics) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
-func (self *Topics) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Topics) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	self.setDefaultElementNames()
+	if self.Keyset {
+		return self.keysetContext(ctx, db, t, ARGS, extra...)
+	}
+	lists := make([]map[string]interface{}, 0)
+	err := self.streamContext(ctx, db, t, ARGS, func(item map[string]interface{}) error {
+		lists = append(lists, item)
+		return nil
+	}, extra...)
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// StreamActionContext runs the same query as RunActionContext, but passes
+// the rows to 'fn' one at a time, instead of collecting them. In the keyset
+// mode, the page is read first and then passed to 'fn'.
+//
+func (self *Topics) StreamActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, fn func(map[string]interface{}) error, extra ...map[string]interface{}) error {
 	self.setDefaultElementNames()
+	if self.Keyset {
+		lists, err := self.keysetContext(ctx, db, t, ARGS, extra...)
+		if err != nil {
+			return err
+		}
+		for _, item := range lists {
+			if err = fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return self.streamContext(ctx, db, t, ARGS, fn, extra...)
+}
+
+func (self *Topics) streamContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, fn func(map[string]interface{}) error, extra ...map[string]interface{}) error {
 	sql, labels, table := t.filterPars(ARGS, self.FIELDS, self.Joints)
 	err := self.pagination(ctx, db, t, ARGS, extra...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	order := self.orderString(t, ARGS)
 
-	dbi := &DBI{DB: db}
-	lists := make([]map[string]interface{}, 0)
+	dbi := &DBI{Executor: db}
+	var values []interface{}
 	if hasValue(extra) && hasValue(extra[0]) {
-		where, values := selectCondition(extra[0], table)
+		var where string
+		where, values, err = selectCondition(extra[0], table, t.questionNumber, t.Columns)
+		if err != nil {
+			return err
+		}
 		if where != "" {
 			sql += "\nWHERE " + where
 		}
-		if order != "" {
-			sql += "\n" + order
-		}
-		if t.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
-		err := dbi.SelectSQLContext(ctx, &lists, sql, labels, values...)
-		if err != nil {
-			return nil, err
-		}
-		return lists, nil
 	}
 
 	if order != "" {
 		sql += "\n" + order
 	}
 
-	if t.questionNumber == Postgres { sql = questionMarkerNumber(sql) }
-	err = dbi.SelectSQLContext(ctx, &lists, sql, labels)
-	return lists, err
+	sql = questionMarker(sql, t.questionNumber)
+	return dbi.StreamSQLContext(ctx, fn, sql, labels, values...)
 }
@@ -0,0 +1,437 @@
+package godbi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TypeScanner scans a column of a label type. The same scanner is used
+// for the column in all rows, and Result returns the value of the last
+// scanned row, or false if it is NULL.
+//
+type TypeScanner interface {
+	Scan(src interface{}) error
+	Result() (interface{}, bool)
+}
+
+// typeScanners keeps the scanners registered by RegisterType.
+//
+var typeScanners sync.Map
+
+// RegisterType registers the scanner of a label type, so a column of the
+// TypeName, or a label of the type, is scanned by a new TypeScanner from fn
+// in each query. The registry is shared by the process, so the names of the
+// built-in types, like 'int' and 'decimal', are refused.
+//
+func RegisterType(typeName string, fn func() TypeScanner) error {
+	if _, ok := sqlTypes[typeName]; ok || typeName == "" {
+		return fmt.Errorf("type %s is built in", typeName)
+	}
+	typeScanners.Store(typeName, fn)
+	return nil
+}
+
+// locations keeps the time zones of columns by name.
+//
+var locations sync.Map
+
+// columnLocation returns the time zone of name, or nil if name is empty
+// or not a zone of the IANA database.
+//
+func columnLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// newTypeScanner returns the scanner of a registered or an extended label
+// type, or nil for the basic types. Times are returned in loc.
+//
+func newTypeScanner(typeName string, loc *time.Location) TypeScanner {
+	if fn, ok := typeScanners.Load(typeName); ok {
+		return fn.(func() TypeScanner)()
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch typeName {
+	case "uint64":
+		return new(uint64Scanner)
+	case "decimal":
+		return new(decimalScanner)
+	case "big.Rat":
+		return &decimalScanner{rat: true}
+	case "uuid":
+		return new(uuidScanner)
+	case "json":
+		return new(jsonScanner)
+	case "[]string", "[]int64", "[]float64", "[]bool":
+		return &arrayScanner{elem: typeName[2:]}
+	case "date", "timestamp", "timestamptz":
+		return &timeScanner{kind: typeName, loc: loc}
+	default:
+	}
+	return nil
+}
+
+// srcString returns the text of a string or []byte source.
+//
+func srcString(src interface{}) (string, bool) {
+	switch v := src.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+	}
+	return "", false
+}
+
+// uint64Scanner scans an unsigned 64-bit integer, which does not fit into int64.
+//
+type uint64Scanner struct {
+	value uint64
+	valid bool
+}
+
+func (self *uint64Scanner) Scan(src interface{}) error {
+	self.value, self.valid = 0, src != nil
+	switch v := src.(type) {
+	case nil:
+	case uint64:
+		self.value = v
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("negative value for uint64: %d", v)
+		}
+		self.value = uint64(v)
+	default:
+		str, ok := srcString(src)
+		if !ok {
+			return fmt.Errorf("wrong value for uint64: %#v", src)
+		}
+		x, err := strconv.ParseUint(strings.TrimSpace(str), 10, 64)
+		if err != nil {
+			return err
+		}
+		self.value = x
+	}
+	return nil
+}
+
+func (self *uint64Scanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
+
+// decimalScanner scans an exact decimal, as a string or as *big.Rat,
+// instead of a float64 which may lose digits.
+//
+type decimalScanner struct {
+	rat   bool
+	value string
+	valid bool
+}
+
+func (self *decimalScanner) Scan(src interface{}) error {
+	self.value, self.valid = "", src != nil
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case int64:
+		self.value = strconv.FormatInt(v, 10)
+	case float64:
+		self.value = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		str, ok := srcString(src)
+		if !ok {
+			return fmt.Errorf("wrong value for decimal: %#v", src)
+		}
+		self.value = strings.TrimSpace(str)
+	}
+	if _, ok := new(big.Rat).SetString(self.value); !ok {
+		return fmt.Errorf("wrong value for decimal: %s", self.value)
+	}
+	return nil
+}
+
+func (self *decimalScanner) Result() (interface{}, bool) {
+	if !self.valid {
+		return nil, false
+	}
+	if self.rat {
+		r, _ := new(big.Rat).SetString(self.value)
+		return r, true
+	}
+	return self.value, true
+}
+
+// uuidScanner scans a UUID, from 16 bytes or from text, into the
+// canonical form of lower-case hexadecimal groups 8-4-4-4-12.
+//
+type uuidScanner struct {
+	value string
+	valid bool
+}
+
+func (self *uuidScanner) Scan(src interface{}) error {
+	self.value, self.valid = "", src != nil
+	if src == nil {
+		return nil
+	}
+	var raw []byte
+	if bs, ok := src.([]byte); ok && len(bs) == 16 {
+		raw = bs
+	} else {
+		str, ok := srcString(src)
+		if !ok {
+			return fmt.Errorf("wrong value for uuid: %#v", src)
+		}
+		str = strings.Trim(strings.TrimSpace(str), "{}")
+		var err error
+		if raw, err = hex.DecodeString(strings.Replace(str, "-", "", -1)); err != nil || len(raw) != 16 {
+			return fmt.Errorf("wrong value for uuid: %s", str)
+		}
+	}
+	h := hex.EncodeToString(raw)
+	self.value = h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+	return nil
+}
+
+func (self *uuidScanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
+
+// jsonScanner scans a JSON column, decoded into map, slice or scalar.
+//
+type jsonScanner struct {
+	value interface{}
+	valid bool
+}
+
+func (self *jsonScanner) Scan(src interface{}) error {
+	self.value, self.valid = nil, src != nil
+	if src == nil {
+		return nil
+	}
+	str, ok := srcString(src)
+	if !ok {
+		return fmt.Errorf("wrong value for json: %#v", src)
+	}
+	return json.Unmarshal([]byte(str), &self.value)
+}
+
+func (self *jsonScanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
+
+// arrayScanner scans a one-dimensional array, in the Postgres format
+// {a,"b c",NULL} or as a JSON array, into a slice of elem. A NULL
+// element is the zero value.
+//
+type arrayScanner struct {
+	elem  string
+	value interface{}
+	valid bool
+}
+
+func (self *arrayScanner) Scan(src interface{}) error {
+	self.value, self.valid = nil, src != nil
+	if src == nil {
+		return nil
+	}
+	str, ok := srcString(src)
+	if !ok {
+		return fmt.Errorf("wrong value for array: %#v", src)
+	}
+	items, err := parseArray(strings.TrimSpace(str))
+	if err != nil {
+		return err
+	}
+
+	switch self.elem {
+	case "string":
+		out := make([]string, len(items))
+		for i, item := range items {
+			if item != nil {
+				out[i] = *item
+			}
+		}
+		self.value = out
+	case "int64":
+		out := make([]int64, len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if out[i], err = strconv.ParseInt(*item, 10, 64); err != nil {
+				return err
+			}
+		}
+		self.value = out
+	case "float64":
+		out := make([]float64, len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if out[i], err = strconv.ParseFloat(*item, 64); err != nil {
+				return err
+			}
+		}
+		self.value = out
+	case "bool":
+		out := make([]bool, len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if out[i], err = strconv.ParseBool(*item); err != nil {
+				return err
+			}
+		}
+		self.value = out
+	default:
+		return fmt.Errorf("wrong array element type: %s", self.elem)
+	}
+	return nil
+}
+
+func (self *arrayScanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
+
+// parseArray parses an array in the Postgres or JSON format into its
+// elements as text, nil for NULL.
+//
+func parseArray(str string) ([]*string, error) {
+	if strings.HasPrefix(str, "[") {
+		var items []interface{}
+		if err := json.Unmarshal([]byte(str), &items); err != nil {
+			return nil, err
+		}
+		out := make([]*string, len(items))
+		for i, item := range items {
+			if item != nil {
+				s := fmt.Sprint(item)
+				out[i] = &s
+			}
+		}
+		return out, nil
+	}
+
+	if len(str) < 2 || str[0] != '{' || str[len(str)-1] != '}' {
+		return nil, fmt.Errorf("wrong array: %s", str)
+	}
+	body := str[1 : len(str)-1]
+	var out []*string
+	if body == "" {
+		return out, nil
+	}
+	for i := 0; i <= len(body); {
+		var item strings.Builder
+		quoted := false
+		if i < len(body) && body[i] == '"' {
+			quoted = true
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' && i+1 < len(body) {
+					i++
+				}
+				item.WriteByte(body[i])
+			}
+			if i >= len(body) {
+				return nil, fmt.Errorf("wrong array: %s", str)
+			}
+			i++
+		} else {
+			for ; i < len(body) && body[i] != ','; i++ {
+				if body[i] == '{' {
+					return nil, fmt.Errorf("multi-dimensional array not supported: %s", str)
+				}
+				item.WriteByte(body[i])
+			}
+		}
+		s := item.String()
+		if !quoted {
+			s = strings.TrimSpace(s)
+		}
+		if !quoted && strings.EqualFold(s, "NULL") {
+			out = append(out, nil)
+		} else {
+			out = append(out, &s)
+		}
+		if i < len(body) && body[i] != ',' {
+			return nil, fmt.Errorf("wrong array: %s", str)
+		}
+		i++
+	}
+	return out, nil
+}
+
+// timeScanner scans a date, a timestamp without time zone, whose wall
+// clock is taken in loc, or a timestamptz, whose instant is shown in loc.
+//
+type timeScanner struct {
+	kind  string
+	loc   *time.Location
+	value time.Time
+	valid bool
+}
+
+func (self *timeScanner) Scan(src interface{}) error {
+	self.value, self.valid = time.Time{}, src != nil
+	if src == nil {
+		return nil
+	}
+	var t time.Time
+	if v, ok := src.(time.Time); ok {
+		t = v
+	} else {
+		str, ok := srcString(src)
+		if !ok {
+			return fmt.Errorf("wrong value for %s: %#v", self.kind, src)
+		}
+		str = strings.TrimSpace(str)
+		parsed := false
+		for _, layout := range timeLayouts {
+			var err error
+			if t, err = time.Parse(layout, str); err == nil {
+				parsed = true
+				break
+			}
+		}
+		if !parsed {
+			return fmt.Errorf("wrong value for %s: %s", self.kind, str)
+		}
+	}
+
+	switch self.kind {
+	case "date":
+		self.value = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, self.loc)
+	case "timestamp":
+		self.value = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), self.loc)
+	default:
+		self.value = t.In(self.loc)
+	}
+	return nil
+}
+
+func (self *timeScanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
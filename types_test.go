@@ -0,0 +1,163 @@
+package godbi
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type upperScanner struct {
+	value string
+	valid bool
+}
+
+func (self *upperScanner) Scan(src interface{}) error {
+	str, ok := srcString(src)
+	self.value, self.valid = strings.ToUpper(str), ok
+	return nil
+}
+
+func (self *upperScanner) Result() (interface{}, bool) {
+	return self.value, self.valid
+}
+
+func TestTypeScanners(t *testing.T) {
+	scan := func(typeName string, src interface{}, loc *time.Location) (interface{}, bool, error) {
+		scanner := newTypeScanner(typeName, loc)
+		if scanner == nil {
+			t.Fatalf("no scanner for %s", typeName)
+		}
+		err := scanner.Scan(src)
+		value, ok := scanner.Result()
+		return value, ok, err
+	}
+
+	if v, ok, err := scan("uint64", []byte("18446744073709551615"), nil); err != nil || !ok || v != uint64(18446744073709551615) {
+		t.Errorf("%#v %v", v, err)
+	}
+	if _, _, err := scan("uint64", int64(-1), nil); err == nil {
+		t.Errorf("negative uint64 expected to fail")
+	}
+	if v, ok, err := scan("decimal", []byte("12345678901234567890.0123456789"), nil); err != nil || !ok || v != "12345678901234567890.0123456789" {
+		t.Errorf("%#v %v", v, err)
+	}
+	if v, _, err := scan("decimal", float64(1.5), nil); err != nil || v != "1.5" {
+		t.Errorf("%#v %v", v, err)
+	}
+	if _, _, err := scan("decimal", "x", nil); err == nil {
+		t.Errorf("wrong decimal expected to fail")
+	}
+	if v, _, err := scan("big.Rat", "0.1", nil); err != nil || v.(*big.Rat).Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("%#v %v", v, err)
+	}
+	if v, ok, err := scan("decimal", nil, nil); err != nil || ok || v != nil {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	uuid := "0f8fad5b-d9cb-469f-a165-70867728950e"
+	raw := []byte{0x0f, 0x8f, 0xad, 0x5b, 0xd9, 0xcb, 0x46, 0x9f, 0xa1, 0x65, 0x70, 0x86, 0x77, 0x28, 0x95, 0x0e}
+	for _, src := range []interface{}{raw, strings.ToUpper(uuid), "{" + uuid + "}", strings.Replace(uuid, "-", "", -1)} {
+		if v, _, err := scan("uuid", src, nil); err != nil || v != uuid {
+			t.Errorf("%#v %v", v, err)
+		}
+	}
+	if _, _, err := scan("uuid", "0f8fad5b", nil); err == nil {
+		t.Errorf("short uuid expected to fail")
+	}
+
+	if v, _, err := scan("json", []byte(`{"a":[1,"b"],"c":null}`), nil); err != nil ||
+		!reflect.DeepEqual(v, map[string]interface{}{"a": []interface{}{float64(1), "b"}, "c": nil}) {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	for typeName, cases := range map[string]map[string]interface{}{
+		"[]string":  {`{a,"b c","d,\"e\"",NULL}`: []string{"a", "b c", `d,"e"`, ""}, `{}`: []string{}, `["x","y"]`: []string{"x", "y"}},
+		"[]int64":   {`{1,2,NULL}`: []int64{1, 2, 0}, `[3,4]`: []int64{3, 4}},
+		"[]float64": {`{1.5,-2}`: []float64{1.5, -2}},
+		"[]bool":    {`{t,f,true}`: []bool{true, false, true}},
+	} {
+		for src, expected := range cases {
+			if v, _, err := scan(typeName, src, nil); err != nil || !reflect.DeepEqual(v, expected) {
+				t.Errorf("%s %s: %#v %v", typeName, src, v, err)
+			}
+		}
+	}
+	if _, _, err := scan("[]int64", `{{1,2},{3,4}}`, nil); err == nil {
+		t.Errorf("multi-dimensional array expected to fail")
+	}
+
+	loc := time.FixedZone("EST", -5*3600)
+	instant := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if v, _, err := scan("timestamptz", instant, loc); err != nil || !v.(time.Time).Equal(instant) || v.(time.Time).Hour() != 0 {
+		t.Errorf("%#v %v", v, err)
+	}
+	if v, _, err := scan("timestamp", "2021-03-04 05:06:07", loc); err != nil || v.(time.Time).Hour() != 5 || v.(time.Time).Location() != loc {
+		t.Errorf("%#v %v", v, err)
+	}
+	if v, _, err := scan("date", []byte("2021-03-04"), loc); err != nil || !v.(time.Time).Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, loc)) {
+		t.Errorf("%#v %v", v, err)
+	}
+	if v, _, err := scan("timestamptz", "2021-03-04 05:06:07+02", nil); err != nil || !v.(time.Time).Equal(instant.Add(-2*time.Hour)) {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	if err := RegisterType("upper", func() TypeScanner { return new(upperScanner) }); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := scan("upper", "abc", nil); err != nil || v != "ABC" {
+		t.Errorf("%#v %v", v, err)
+	}
+	// a built-in type can't be replaced
+	if err := RegisterType("int", func() TypeScanner { return new(upperScanner) }); err == nil {
+		t.Errorf("int registered")
+	}
+	if columnLocation("Nowhere/Nothing") != nil || columnLocation("") != nil {
+		t.Errorf("unknown location loaded")
+	}
+}
+
+func TestTypes(t *testing.T) {
+	db, err := getdb()
+	if err != nil {
+		skipNoDB(t, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	db.Exec(`drop table if exists typed`)
+	if _, err = db.Exec(`create table typed(id int, amount varchar(40), doc text, tags text, code varchar(10), day varchar(10))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(`insert into typed values (1, '12.5', '{"a":1}', '["x","y"]', 'abc', '2021-03-04')`); err != nil {
+		t.Fatal(err)
+	}
+	RegisterType("upper", func() TypeScanner { return new(upperScanner) })
+
+	model := &Model{Table: Table{TableName: "typed", Pks: []string{"id"}, Columns: []*Col{
+		{ColumnName: "id", Label: "id", TypeName: "uint64"},
+		{ColumnName: "amount", Label: "amount", TypeName: "decimal"},
+		{ColumnName: "doc", Label: "doc", TypeName: "json"},
+		{ColumnName: "tags", Label: "tags", TypeName: "[]string"},
+		{ColumnName: "code", Label: "code", TypeName: "upper"},
+		{ColumnName: "day", Label: "day", TypeName: "date", Location: "Asia/Tokyo"},
+	}}, Actions: []Capability{&Topics{Action: Action{ActionName: "topics"}}}}
+	model.SetQuestionNumber(testDBType)
+	lists, err := model.RunModelContext(ctx, db, "topics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+	expected := map[string]interface{}{"id": uint64(1), "amount": "12.5", "doc": map[string]interface{}{"a": float64(1)},
+		"tags": []string{"x", "y"}, "code": "ABC", "day": time.Date(2021, 3, 4, 0, 0, 0, 0, tokyo)}
+	if len(lists) != 1 || !reflect.DeepEqual(lists[0], expected) {
+		t.Errorf("%#v", lists)
+	}
+
+	db.Exec(`drop table if exists typed`)
+}
@@ -2,8 +2,7 @@ package godbi
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"strings"
 )
 
 type Update struct {
@@ -11,29 +10,28 @@ type Update struct {
 	Empties []string `json:"empties,omitempty" hcl:"empties,optional"`
 }
 
-func (self *Update) RunAction(db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+func (self *Update) RunAction(db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
 	return self.RunActionContext(context.Background(), db, t, ARGS, extra...)
 }
 
-func (self *Update) RunActionContext(ctx context.Context, db *sql.DB, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
-	if self.IsDo {
-		if err := t.checkNull(ARGS, extra...); err != nil {
-			return nil, err
-		}
+func (self *Update) RunActionContext(ctx context.Context, db Executor, t *Table, ARGS map[string]interface{}, extra ...map[string]interface{}) ([]map[string]interface{}, error) {
+	ARGS, err := t.validate(ARGS, self.IsDo, extra...)
+	if err != nil {
+		return nil, err
 	}
 
 	ids := t.getIdVal(ARGS, extra...)
 	if !hasValue(ids) {
-		return nil, fmt.Errorf("pk value not found")
+		return nil, newError(ErrValidation, strings.Join(t.Pks, ", "), "pk value not found")
 	}
 
 	fieldValues := t.getFv(ARGS)
 	if !hasValue(fieldValues) {
-		return nil, fmt.Errorf("no data to update")
+		return nil, newError(ErrValidation, "", "no data to update")
 	} else if len(fieldValues) == 1 && fieldValues[t.Pks[0]] != nil {
 		return fromFv(fieldValues), nil
 	}
 
-	err := t.updateHashNullsContext(ctx, db, fieldValues, ids, self.Empties, extra...)
+	err = t.updateHashNullsContext(ctx, db, fieldValues, ids, self.Empties, extra...)
 	return fromFv(fieldValues), err
 }
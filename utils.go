@@ -7,10 +7,14 @@ import (
 )
 
 func questionMarkerNumber(query string) string {
+	return questionMarkerPrefix(query, `$`)
+}
+
+func questionMarkerPrefix(query, prefix string) string {
 	re := regexp.MustCompile(`\?`)
 	i := 1
 	repl := func(in string) string {
-		x := `$` + strconv.Itoa(i)
+		x := prefix + strconv.Itoa(i)
 		i++
 		return x
 	}
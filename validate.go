@@ -0,0 +1,529 @@
+package godbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is the validation error of an input field.
+//
+type FieldError struct {
+	Field   string      `json:"field"`
+	Value   interface{} `json:"value,omitempty"`
+	Message string      `json:"message"`
+}
+
+func (self *FieldError) Error() string {
+	return self.Field + ": " + self.Message
+}
+
+// ValidationError lists the errors of all invalid fields of an input,
+// found before any statement runs.
+//
+type ValidationError struct {
+	Fields []*FieldError `json:"fields"`
+}
+
+func (self *ValidationError) Error() string {
+	var msgs []string
+	for _, f := range self.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// patternCache caches the compiled Col.Pattern.
+//
+var patternCache sync.Map
+
+// intRange returns the range of an integer label type, and false if the
+// type is not an integer.
+//
+func intRange(typeName string) (float64, float64, bool) {
+	switch typeName {
+	case "int8":
+		return math.MinInt8, math.MaxInt8, true
+	case "int16":
+		return math.MinInt16, math.MaxInt16, true
+	case "int", "int32":
+		return math.MinInt32, math.MaxInt32, true
+	case "int64":
+		return math.MinInt64, math.MaxInt64, true
+	case "uint8":
+		return 0, math.MaxUint8, true
+	case "uint16":
+		return 0, math.MaxUint16, true
+	case "uint", "uint32":
+		return 0, math.MaxUint32, true
+	case "uint64":
+		return 0, math.MaxUint64, true
+	default:
+	}
+	return 0, 0, false
+}
+
+// coerce converts value, often a string of a HTTP form, into the label type
+// of the column, and returns it with its numeric size: the number itself,
+// or the length of a string.
+//
+func (self *Col) coerce(value interface{}) (interface{}, float64, error) {
+	if min, max, ok := intRange(self.TypeName); ok {
+		var x float64
+		switch v := value.(type) {
+		case string:
+			str := strings.TrimSpace(v)
+			if strings.HasPrefix(self.TypeName, "uint") {
+				u, err := strconv.ParseUint(str, 10, 64)
+				if err != nil {
+					return nil, 0, fmt.Errorf("not an unsigned integer")
+				}
+				if float64(u) > max {
+					return nil, 0, fmt.Errorf("out of range of %s", self.TypeName)
+				}
+				return u, float64(u), nil
+			}
+			i, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("not an integer")
+			}
+			value, x = i, float64(i)
+		case json.Number:
+			i, err := v.Int64()
+			if err != nil {
+				return nil, 0, fmt.Errorf("not an integer")
+			}
+			value, x = i, float64(i)
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, 0, fmt.Errorf("not an integer")
+			}
+			value, x = int64(v), v
+		case float32:
+			if float64(v) != math.Trunc(float64(v)) {
+				return nil, 0, fmt.Errorf("not an integer")
+			}
+			value, x = int64(v), float64(v)
+		case int:
+			x = float64(v)
+		case int8:
+			x = float64(v)
+		case int16:
+			x = float64(v)
+		case int32:
+			x = float64(v)
+		case int64:
+			x = float64(v)
+		case uint:
+			x = float64(v)
+		case uint8:
+			x = float64(v)
+		case uint16:
+			x = float64(v)
+		case uint32:
+			x = float64(v)
+		case uint64:
+			x = float64(v)
+		default:
+			return nil, 0, fmt.Errorf("not an integer")
+		}
+		if x < min || x > max {
+			return nil, 0, fmt.Errorf("out of range of %s", self.TypeName)
+		}
+		return value, x, nil
+	}
+
+	switch self.TypeName {
+	case "float32", "float64":
+		switch v := value.(type) {
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("not a number")
+			}
+			return f, f, nil
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, 0, fmt.Errorf("not a number")
+			}
+			return f, f, nil
+		case float64:
+			return v, v, nil
+		case float32:
+			return v, float64(v), nil
+		case int:
+			return v, float64(v), nil
+		case int64:
+			return v, float64(v), nil
+		default:
+		}
+		return nil, 0, fmt.Errorf("not a number")
+	case "decimal", "big.Rat":
+		str := strings.TrimSpace(fmt.Sprint(value))
+		r, ok := new(big.Rat).SetString(str)
+		if !ok {
+			return nil, 0, fmt.Errorf("not a decimal")
+		}
+		f, _ := r.Float64()
+		if _, isString := value.(string); isString {
+			return str, f, nil
+		}
+		return value, f, nil
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, 0, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "1", "t", "true", "y", "yes", "on":
+				return true, 0, nil
+			case "0", "f", "false", "n", "no", "off", "":
+				return false, 0, nil
+			default:
+			}
+		case int:
+			return v != 0, 0, nil
+		case int64:
+			return v != 0, 0, nil
+		case float64:
+			return v != 0, 0, nil
+		default:
+		}
+		return nil, 0, fmt.Errorf("not a boolean")
+	case "time", "date", "timestamp", "timestamptz":
+		switch v := value.(type) {
+		case time.Time:
+			return v, 0, nil
+		case string:
+			str := strings.TrimSpace(v)
+			for _, layout := range timeLayouts {
+				if _, err := time.Parse(layout, str); err == nil {
+					return str, 0, nil
+				}
+			}
+		default:
+		}
+		return nil, 0, fmt.Errorf("not a valid time")
+	case "uuid":
+		scanner := new(uuidScanner)
+		if err := scanner.Scan(value); err != nil {
+			return nil, 0, fmt.Errorf("not a uuid")
+		}
+		return value, 0, nil
+	default:
+	}
+
+	// strings and any other type
+	if str, ok := value.(string); ok {
+		return str, float64(utf8.RuneCountInString(str)), nil
+	}
+	return value, 0, nil
+}
+
+// check checks value, coerced into the column type, against the
+// constraints of the column.
+//
+func (self *Col) check(value interface{}, size float64) error {
+	isNumber := false
+	if _, _, ok := intRange(self.TypeName); ok {
+		isNumber = true
+	}
+	_, isString := value.(string)
+	switch self.TypeName {
+	case "float32", "float64", "decimal", "big.Rat":
+		isNumber = true
+	case "bool", "time", "date", "timestamp", "timestamptz", "uuid":
+		isString = false
+	default:
+	}
+
+	if isNumber || isString {
+		what := "length"
+		if isNumber {
+			what = "value"
+		}
+		if self.Min != nil && size < *self.Min {
+			return fmt.Errorf("%s less than %v", what, *self.Min)
+		}
+		if self.Max != nil && size > *self.Max {
+			return fmt.Errorf("%s greater than %v", what, *self.Max)
+		}
+	}
+
+	str := fmt.Sprint(value)
+	if self.Pattern != "" {
+		var re *regexp.Regexp
+		if v, ok := patternCache.Load(self.Pattern); ok {
+			re = v.(*regexp.Regexp)
+		} else {
+			var err error
+			if re, err = regexp.Compile(self.Pattern); err != nil {
+				return fmt.Errorf("wrong pattern %s: %v", self.Pattern, err)
+			}
+			patternCache.Store(self.Pattern, re)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("not matching pattern %s", self.Pattern)
+		}
+	}
+	if self.Enum != nil && !grep(self.Enum, str) {
+		return fmt.Errorf("not one of %s", strings.Join(self.Enum, ", "))
+	}
+	return nil
+}
+
+// Validate checks ARGS against the columns: the presence of the Notnull
+// columns, in ARGS or 'extra', the column types, into which the values are
+// coerced, and the constraints Min, Max, Pattern and Enum. It returns the
+// coerced copy of ARGS, or a *ValidationError listing all invalid fields.
+//
+func (self *Table) Validate(ARGS map[string]interface{}, extra ...map[string]interface{}) (map[string]interface{}, error) {
+	return self.validate(ARGS, true, extra...)
+}
+
+// validate is Validate, which checks the Notnull columns only if required.
+//
+func (self *Table) validate(ARGS map[string]interface{}, required bool, extra ...map[string]interface{}) (map[string]interface{}, error) {
+	verr := new(ValidationError)
+	if required {
+		for _, col := range self.Columns {
+			if !col.Notnull || col.Auto {
+				continue
+			} // the column is ok with null
+			if _, ok := ARGS[col.ColumnName]; ok {
+				continue
+			}
+			if hasValue(extra) && hasValue(extra[0]) {
+				if _, ok := extra[0][col.ColumnName]; ok {
+					continue
+				}
+			}
+			verr.Fields = append(verr.Fields, &FieldError{Field: col.ColumnName, Message: "not found in input"})
+		}
+	}
+
+	newArgs := make(map[string]interface{})
+	for k, v := range ARGS {
+		newArgs[k] = v
+	}
+	for _, col := range self.Columns {
+		keys := []string{col.ColumnName}
+		if col.Label != "" && col.Label != col.ColumnName {
+			keys = append(keys, col.Label)
+		}
+		for _, key := range keys {
+			value, ok := ARGS[key]
+			if !ok {
+				continue
+			}
+			switch value.(type) {
+			case nil, []map[string]interface{}, map[string]interface{}, []interface{}:
+				continue
+			default:
+			}
+			coerced, size, err := col.coerce(value)
+			if err == nil {
+				err = col.check(coerced, size)
+			}
+			if err != nil {
+				verr.Fields = append(verr.Fields, &FieldError{Field: key, Value: value, Message: err.Error()})
+				continue
+			}
+			newArgs[key] = coerced
+		}
+	}
+
+	if verr.Fields != nil {
+		return nil, verr
+	}
+	return newArgs, nil
+}
+
+// Validate checks the graph, as loaded from JSON: the targets of prepares
+// and nextpages, the columns or labels in their RelateArgs and RelateExtra,
+// the columns in Pks, IdAuto, Uniques and Fks of each model, and the time
+// zones of the columns. All the problems are reported at once in a
+// *ValidationError, each with its JSON path as Field, e.g.
+// "models[0].actions[1].nextpages[0].tableName".
+//
+func (self *Graph) Validate() error {
+	var fields []*FieldError
+	problem := func(path string, value interface{}, format string, args ...interface{}) {
+		fields = append(fields, &FieldError{Field: path, Value: value, Message: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]bool)
+	for i, item := range self.Models {
+		path := fmt.Sprintf("models[%d]", i)
+		table := item.GetTable()
+		if table.TableName == "" {
+			problem(path+".tableName", nil, "table name is empty")
+		} else if seen[table.TableName] {
+			problem(path+".tableName", table.TableName, "model %s is duplicated", table.TableName)
+		}
+		seen[table.TableName] = true
+
+		columns := make(map[string]bool)
+		for j, col := range table.Columns {
+			columns[col.ColumnName] = true
+			if col.Location != "" && columnLocation(col.Location) == nil {
+				problem(fmt.Sprintf("%s.columns[%d].location", path, j), col.Location, "unknown time zone %s", col.Location)
+			}
+		}
+		for j, pk := range table.Pks {
+			if !columns[pk] {
+				problem(fmt.Sprintf("%s.pks[%d]", path, j), pk, "column %s not declared", pk)
+			}
+		}
+		if table.IdAuto != "" && !columns[table.IdAuto] {
+			problem(path+".idAuto", table.IdAuto, "column %s not declared", table.IdAuto)
+		}
+		for j, unique := range table.Uniques {
+			if !columns[unique] {
+				problem(fmt.Sprintf("%s.uniques[%d]", path, j), unique, "column %s not declared", unique)
+			}
+		}
+		for j, fk := range table.Fks {
+			fkPath := fmt.Sprintf("%s.fks[%d]", path, j)
+			if !columns[fk.Column] {
+				problem(fkPath+".column", fk.Column, "column %s not declared", fk.Column)
+			}
+			// the referenced table may be out of the graph
+			if other := self.GetModel(fk.FkTable); other != nil && !graphNames(other)[fk.FkColumn] {
+				problem(fkPath+".fkColumn", fk.FkColumn, "column %s not declared in %s", fk.FkColumn, fk.FkTable)
+			}
+		}
+
+		model, ok := item.(*Model)
+		if !ok {
+			continue
+		}
+		for j, action := range model.Actions {
+			actionPath := fmt.Sprintf("%s.actions[%d]", path, j)
+			names := self.actionNames(item, action)
+			for k, p := range action.GetPrepares() {
+				self.validateConnection(problem, fmt.Sprintf("%s.prepares[%d]", actionPath, k), p, names, true)
+			}
+			for k, p := range action.GetNextpages() {
+				self.validateConnection(problem, fmt.Sprintf("%s.nextpages[%d]", actionPath, k), p, names, false)
+			}
+		}
+	}
+
+	if fields != nil {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateConnection checks that the target of connection p exists, and
+// the names in its relations. For a nextpage, the keys are names of the
+// current model, and the values of the next. A prepare maps both ways, so
+// either model is accepted.
+//
+func (self *Graph) validateConnection(problem func(string, interface{}, string, ...interface{}), path string, p *Connection, names map[string]bool, prepare bool) {
+	next := self.GetModel(p.TableName)
+	if next == nil {
+		problem(path+".tableName", p.TableName, "model %s not found in graph", p.TableName)
+		return
+	}
+	nextAction := next.GetAction(p.ActionName)
+	if nextAction == nil {
+		problem(path+".actionName", p.ActionName, "action %s not found in model %s", p.ActionName, p.TableName)
+	}
+
+	nextNames := self.actionNames(next, nextAction)
+	for _, relate := range []struct {
+		name  string
+		which map[string]string
+	}{{"relateArgs", p.RelateArgs}, {"relateExtra", p.RelateExtra}} {
+		if _, ok := relate.which["ALL"]; ok {
+			continue
+		}
+		var keys []string
+		for k := range relate.which {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := relate.which[k]
+			keyOK, valueOK := names[k], nextNames[v]
+			if prepare {
+				keyOK, valueOK = keyOK || nextNames[k], valueOK || names[v]
+			}
+			if !keyOK {
+				problem(path+"."+relate.name+"."+k, k, "%s is not a column or label of the current model", k)
+			}
+			if !valueOK {
+				problem(path+"."+relate.name+"."+k, v, "%s is not a column or label of %s", v, p.TableName)
+			}
+		}
+	}
+}
+
+// actionNames returns the names known to action of model: those of the
+// model, the parameters and labels of SQL and Proc, and the columns of the
+// tables joined in Topics and Edit, if they are models of the graph.
+//
+func (self *Graph) actionNames(model Navigate, action Capability) map[string]bool {
+	names := graphNames(model)
+	var pars []string
+	var cols []*Col
+	var joints []*Joint
+	switch t := action.(type) {
+	case *SQL:
+		pars, cols = t.Pars, t.Labels
+	case *Proc:
+		pars, cols = t.Pars, append(append(cols, t.Outs...), t.Labels...)
+	case *Topics:
+		joints = t.Joints
+	case *Edit:
+		joints = t.Joints
+	default:
+	}
+	for _, par := range pars {
+		names[par] = true
+	}
+	for _, col := range cols {
+		names[col.ColumnName] = true
+		if col.Label != "" {
+			names[col.Label] = true
+		}
+	}
+	for _, joint := range joints {
+		other := self.GetModel(joint.TableName)
+		if other == nil {
+			continue
+		}
+		for name := range graphNames(other) {
+			names[name] = true
+			names[joint.getAlias()+"."+name] = true
+		}
+	}
+	return names
+}
+
+// graphNames returns the column names and labels of a model.
+//
+func graphNames(model Navigate) map[string]bool {
+	names := make(map[string]bool)
+	for _, col := range model.GetTable().Columns {
+		names[col.ColumnName] = true
+		if i := strings.LastIndex(col.ColumnName, "."); i >= 0 {
+			names[col.ColumnName[i+1:]] = true
+		}
+		if col.Label != "" {
+			names[col.Label] = true
+		}
+	}
+	return names
+}
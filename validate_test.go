@@ -0,0 +1,186 @@
+package godbi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	table := new(Table)
+	err := json.Unmarshal([]byte(`{"tableName":"m_v", "pks":["id"], "idAuto":"id", "columns":[
+{"columnName":"id", "label":"id", "typeName":"int", "auto":true},
+{"columnName":"age", "label":"age", "typeName":"int8", "notnull":true, "min":0, "max":120},
+{"columnName":"price", "label":"price", "typeName":"float64"},
+{"columnName":"amount", "label":"amount", "typeName":"decimal", "max":1000},
+{"columnName":"flag", "label":"flag", "typeName":"bool"},
+{"columnName":"born", "label":"born", "typeName":"date"},
+{"columnName":"code", "label":"Code", "typeName":"string", "max":5, "pattern":"^[a-z]+$"},
+{"columnName":"name", "label":"name", "typeName":"string", "notnull":true, "min":2},
+{"columnName":"color", "label":"color", "typeName":"string", "enum":["red","green"]}
+]}`), table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := table.Validate(map[string]interface{}{"id": "3", "age": " 42", "price": "1.5", "amount": "12.30", "flag": "on",
+		"born": "2001-02-03", "Code": "abc", "name": "john", "color": "red", "other": "x", "m_b": map[string]interface{}{"child": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"id": int64(3), "age": int64(42), "price": 1.5, "amount": "12.30", "flag": true,
+		"born": "2001-02-03", "Code": "abc", "name": "john", "color": "red", "other": "x", "m_b": map[string]interface{}{"child": 1}}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("%#v", args)
+	}
+
+	// the same valid values of other types
+	if _, err = table.Validate(map[string]interface{}{"age": float64(42), "name": "jo", "flag": 0, "amount": 999}); err != nil {
+		t.Error(err)
+	}
+
+	_, err = table.Validate(map[string]interface{}{"age": "200", "price": "x", "amount": "1001", "flag": "maybe",
+		"born": "2001-02-30", "code": "abcdef", "Code": "AB", "color": "blue"}, map[string]interface{}{"name": "y"})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("%#v", err)
+	}
+	var got []string
+	for _, f := range verr.Fields {
+		got = append(got, f.Error())
+	}
+	if !reflect.DeepEqual(got, []string{
+		"age: out of range of int8",
+		"price: not a number",
+		"amount: value greater than 1000",
+		"flag: not a boolean",
+		"born: not a valid time",
+		"code: length greater than 5",
+		"Code: not matching pattern ^[a-z]+$",
+		"color: not one of red, green",
+	}) {
+		t.Errorf("%#v", got)
+	}
+
+	_, err = table.Validate(map[string]interface{}{"age": -1, "name": "j"})
+	if err == nil || err.Error() != "validation failed: age: value less than 0; name: length less than 2" {
+		t.Errorf("%v", err)
+	}
+	_, err = table.Validate(map[string]interface{}{"age": 1})
+	if err == nil || err.Error() != "validation failed: name: not found in input" {
+		t.Errorf("%v", err)
+	}
+
+	// an insert fails before any statement runs, on a nil db
+	insert := &Insert{Action: Action{ActionName: "insert", IsDo: true}}
+	if _, err = insert.RunActionContext(context.Background(), nil, table, map[string]interface{}{"age": "x", "name": "john"}); err == nil {
+		t.Errorf("validation expected to fail")
+	} else if verr, ok := err.(*ValidationError); !ok || len(verr.Fields) != 1 || verr.Fields[0].Field != "age" || verr.Fields[0].Value != "x" {
+		t.Errorf("%#v", err)
+	}
+	bs, _ := json.Marshal(err)
+	if string(bs) != `{"fields":[{"field":"age","value":"x","message":"not an integer"}]}` {
+		t.Errorf("%s", bs)
+	}
+}
+
+func TestGraphValidate(t *testing.T) {
+	graph, err := NewGraphJsonFile("graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = graph.Validate(); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id", "pk"], "idAuto":"auto", "uniques":["x"],
+"columns":[{"columnName":"x", "label":"x_label", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"fks":[{"fkTable":"m_b", "fkColumn":"bid", "column":"id"}],
+"actions":[{"actionName":"topics", "nextpages":[
+	{"tableName":"m_c", "actionName":"topics"},
+	{"tableName":"m_b", "actionName":"wrong"},
+	{"tableName":"m_b", "actionName":"topics", "relateExtra":{"x_label":"id", "y":"z"}}]},
+	{"actionName":"edit", "prepares":[{"tableName":"m_b", "actionName":"edit", "relateArgs":{"tid":"id"}}]}]},
+{"tableName":"m_b", "pks":["tid"], "columns":[{"columnName":"tid", "label":"tid", "typeName":"int"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics"}, {"actionName":"edit"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = graph.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("%v", err)
+	}
+	var paths []string
+	for _, f := range verr.Fields {
+		paths = append(paths, f.Field)
+	}
+	expected := []string{
+		"models[0].pks[1]",
+		"models[0].idAuto",
+		"models[0].fks[0].fkColumn",
+		"models[0].actions[0].nextpages[0].tableName",
+		"models[0].actions[0].nextpages[1].actionName",
+		"models[0].actions[0].nextpages[2].relateExtra.y",
+		"models[0].actions[0].nextpages[2].relateExtra.y",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("%v", err)
+	}
+
+	// the labels of SQL and the columns of joined models are known
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"],
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"sql", "statement":"SELECT COUNT(*) AS total FROM m_a WHERE id > ?", "pars":["since"], "labels":[{"columnName":"total", "label":"total", "typeName":"int"}],
+	"nextpages":[{"tableName":"m_b", "actionName":"topics", "relateExtra":{"total":"tid"}}]},
+	{"actionName":"topics", "joints":[{"tableName":"m_a", "alias":"a"}, {"tableName":"m_b", "alias":"b", "using":"id"}],
+	"nextpages":[{"tableName":"m_a", "actionName":"sql", "relateExtra":{"b.tid":"since"}}]}]},
+{"tableName":"m_b", "pks":["tid"], "columns":[{"columnName":"tid", "label":"tid", "typeName":"int"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = graph.Validate(); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// the strict loaders check the graph and its cycles
+	if _, err = NewGraphJsonFileStrict("graph.json"); err != nil {
+		t.Errorf("%v", err)
+	}
+	_, err = NewGraphJsonStrict(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"],
+"columns":[{"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "nextpages":[{"tableName":"m_c", "actionName":"topics"}]}]}]}`))
+	if !errors.As(err, &verr) {
+		t.Errorf("%v", err)
+	}
+	_, err = NewGraphJsonStrict(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"],
+"columns":[{"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "nextpages":[{"tableName":"m_a", "actionName":"edit", "relateExtra":{"id":"id"}}]},
+	{"actionName":"edit", "nextpages":[{"tableName":"m_a", "actionName":"topics"}]}]}]}`))
+	if err == nil {
+		t.Errorf("cycle not found")
+	}
+
+	// a missing target fails the run, instead of panicking
+	graph, err = NewGraphJson(json.RawMessage(`{"models":[{"tableName":"m_a", "pks":["id"],
+"columns":[{"columnName":"x", "label":"x", "typeName":"string"}, {"columnName":"id", "label":"id", "typeName":"int"}],
+"actions":[{"actionName":"topics", "nextpages":[{"tableName":"m_c", "actionName":"topics"}]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, ctx, _ := local2Vars(t)
+	defer db.Close()
+	graph.SetQuestionNumber(testDBType)
+	db.Exec(`INSERT INTO m_a (x, y) VALUES ('a', 'b')`)
+	_, err = graph.RunContext(ctx, db, "m_a", "topics")
+	var e *Error
+	if !errors.Is(err, ErrConfig) || !errors.As(err, &e) || e.Model != "m_c" {
+		t.Errorf("%v", err)
+	}
+
+	db.Exec(`drop table if exists m_a`)
+	db.Exec(`drop table if exists m_b`)
+}